@@ -0,0 +1,322 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/nscope.proto
+
+package nscopev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A host, host:port, IP or URL, as accepted on an input line.
+	Target        string `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchRequest) Reset() {
+	*x = MatchRequest{}
+	mi := &file_api_nscope_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchRequest) ProtoMessage() {}
+
+func (x *MatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_nscope_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchRequest.ProtoReflect.Descriptor instead.
+func (*MatchRequest) Descriptor() ([]byte, []int) {
+	return file_api_nscope_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MatchRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type MatchResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Input string                 `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Host  string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Port  string                 `protobuf:"bytes,3,opt,name=port,proto3" json:"port,omitempty"`
+	// One of "included", "excluded", "unmatched" or "invalid".
+	Verdict string `protobuf:"bytes,4,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	Matched bool   `protobuf:"varint,5,opt,name=matched,proto3" json:"matched,omitempty"`
+	Rule    string `protobuf:"bytes,6,opt,name=rule,proto3" json:"rule,omitempty"`
+	// Position of the deciding scope entry, or -1 when none matched.
+	RuleIndex     int32 `protobuf:"varint,7,opt,name=rule_index,json=ruleIndex,proto3" json:"rule_index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchResult) Reset() {
+	*x = MatchResult{}
+	mi := &file_api_nscope_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchResult) ProtoMessage() {}
+
+func (x *MatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_api_nscope_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchResult.ProtoReflect.Descriptor instead.
+func (*MatchResult) Descriptor() ([]byte, []int) {
+	return file_api_nscope_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MatchResult) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *MatchResult) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *MatchResult) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
+func (x *MatchResult) GetVerdict() string {
+	if x != nil {
+		return x.Verdict
+	}
+	return ""
+}
+
+func (x *MatchResult) GetMatched() bool {
+	if x != nil {
+		return x.Matched
+	}
+	return false
+}
+
+func (x *MatchResult) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *MatchResult) GetRuleIndex() int32 {
+	if x != nil {
+		return x.RuleIndex
+	}
+	return 0
+}
+
+type ReloadScopeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadScopeRequest) Reset() {
+	*x = ReloadScopeRequest{}
+	mi := &file_api_nscope_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadScopeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadScopeRequest) ProtoMessage() {}
+
+func (x *ReloadScopeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_nscope_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadScopeRequest.ProtoReflect.Descriptor instead.
+func (*ReloadScopeRequest) Descriptor() ([]byte, []int) {
+	return file_api_nscope_proto_rawDescGZIP(), []int{2}
+}
+
+type ReloadScopeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rules         int32                  `protobuf:"varint,1,opt,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadScopeResponse) Reset() {
+	*x = ReloadScopeResponse{}
+	mi := &file_api_nscope_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadScopeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadScopeResponse) ProtoMessage() {}
+
+func (x *ReloadScopeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_nscope_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadScopeResponse.ProtoReflect.Descriptor instead.
+func (*ReloadScopeResponse) Descriptor() ([]byte, []int) {
+	return file_api_nscope_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReloadScopeResponse) GetRules() int32 {
+	if x != nil {
+		return x.Rules
+	}
+	return 0
+}
+
+var File_api_nscope_proto protoreflect.FileDescriptor
+
+const file_api_nscope_proto_rawDesc = "" +
+	"\n" +
+	"\x10api/nscope.proto\x12\tnscope.v1\"&\n" +
+	"\fMatchRequest\x12\x16\n" +
+	"\x06target\x18\x01 \x01(\tR\x06target\"\xb2\x01\n" +
+	"\vMatchResult\x12\x14\n" +
+	"\x05input\x18\x01 \x01(\tR\x05input\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\x03 \x01(\tR\x04port\x12\x18\n" +
+	"\averdict\x18\x04 \x01(\tR\averdict\x12\x18\n" +
+	"\amatched\x18\x05 \x01(\bR\amatched\x12\x12\n" +
+	"\x04rule\x18\x06 \x01(\tR\x04rule\x12\x1d\n" +
+	"\n" +
+	"rule_index\x18\a \x01(\x05R\truleIndex\"\x14\n" +
+	"\x12ReloadScopeRequest\"+\n" +
+	"\x13ReloadScopeResponse\x12\x14\n" +
+	"\x05rules\x18\x01 \x01(\x05R\x05rules2\xcd\x01\n" +
+	"\x05Scope\x12<\n" +
+	"\x05Match\x12\x17.nscope.v1.MatchRequest\x1a\x16.nscope.v1.MatchResult(\x010\x01\x128\n" +
+	"\x05Check\x12\x17.nscope.v1.MatchRequest\x1a\x16.nscope.v1.MatchResult\x12L\n" +
+	"\vReloadScope\x12\x1d.nscope.v1.ReloadScopeRequest\x1a\x1e.nscope.v1.ReloadScopeResponseB%Z#github.com/nlxz/nscope/api/nscopev1b\x06proto3"
+
+var (
+	file_api_nscope_proto_rawDescOnce sync.Once
+	file_api_nscope_proto_rawDescData []byte
+)
+
+func file_api_nscope_proto_rawDescGZIP() []byte {
+	file_api_nscope_proto_rawDescOnce.Do(func() {
+		file_api_nscope_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_nscope_proto_rawDesc), len(file_api_nscope_proto_rawDesc)))
+	})
+	return file_api_nscope_proto_rawDescData
+}
+
+var file_api_nscope_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_api_nscope_proto_goTypes = []any{
+	(*MatchRequest)(nil),        // 0: nscope.v1.MatchRequest
+	(*MatchResult)(nil),         // 1: nscope.v1.MatchResult
+	(*ReloadScopeRequest)(nil),  // 2: nscope.v1.ReloadScopeRequest
+	(*ReloadScopeResponse)(nil), // 3: nscope.v1.ReloadScopeResponse
+}
+var file_api_nscope_proto_depIdxs = []int32{
+	0, // 0: nscope.v1.Scope.Match:input_type -> nscope.v1.MatchRequest
+	0, // 1: nscope.v1.Scope.Check:input_type -> nscope.v1.MatchRequest
+	2, // 2: nscope.v1.Scope.ReloadScope:input_type -> nscope.v1.ReloadScopeRequest
+	1, // 3: nscope.v1.Scope.Match:output_type -> nscope.v1.MatchResult
+	1, // 4: nscope.v1.Scope.Check:output_type -> nscope.v1.MatchResult
+	3, // 5: nscope.v1.Scope.ReloadScope:output_type -> nscope.v1.ReloadScopeResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_api_nscope_proto_init() }
+func file_api_nscope_proto_init() {
+	if File_api_nscope_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_nscope_proto_rawDesc), len(file_api_nscope_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_nscope_proto_goTypes,
+		DependencyIndexes: file_api_nscope_proto_depIdxs,
+		MessageInfos:      file_api_nscope_proto_msgTypes,
+	}.Build()
+	File_api_nscope_proto = out.File
+	file_api_nscope_proto_goTypes = nil
+	file_api_nscope_proto_depIdxs = nil
+}
@@ -0,0 +1,204 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: api/nscope.proto
+
+package nscopev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Scope_Match_FullMethodName       = "/nscope.v1.Scope/Match"
+	Scope_Check_FullMethodName       = "/nscope.v1.Scope/Check"
+	Scope_ReloadScope_FullMethodName = "/nscope.v1.Scope/ReloadScope"
+)
+
+// ScopeClient is the client API for Scope service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Scope answers scope decisions against the scope loaded by nscope serve.
+type ScopeClient interface {
+	// Match classifies a stream of targets; one result is sent per request, in
+	// order.
+	Match(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[MatchRequest, MatchResult], error)
+	// Check classifies a single target.
+	Check(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResult, error)
+	// ReloadScope re-reads the scope files.
+	ReloadScope(ctx context.Context, in *ReloadScopeRequest, opts ...grpc.CallOption) (*ReloadScopeResponse, error)
+}
+
+type scopeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScopeClient(cc grpc.ClientConnInterface) ScopeClient {
+	return &scopeClient{cc}
+}
+
+func (c *scopeClient) Match(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[MatchRequest, MatchResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Scope_ServiceDesc.Streams[0], Scope_Match_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MatchRequest, MatchResult]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Scope_MatchClient = grpc.BidiStreamingClient[MatchRequest, MatchResult]
+
+func (c *scopeClient) Check(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatchResult)
+	err := c.cc.Invoke(ctx, Scope_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scopeClient) ReloadScope(ctx context.Context, in *ReloadScopeRequest, opts ...grpc.CallOption) (*ReloadScopeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadScopeResponse)
+	err := c.cc.Invoke(ctx, Scope_ReloadScope_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScopeServer is the server API for Scope service.
+// All implementations must embed UnimplementedScopeServer
+// for forward compatibility.
+//
+// Scope answers scope decisions against the scope loaded by nscope serve.
+type ScopeServer interface {
+	// Match classifies a stream of targets; one result is sent per request, in
+	// order.
+	Match(grpc.BidiStreamingServer[MatchRequest, MatchResult]) error
+	// Check classifies a single target.
+	Check(context.Context, *MatchRequest) (*MatchResult, error)
+	// ReloadScope re-reads the scope files.
+	ReloadScope(context.Context, *ReloadScopeRequest) (*ReloadScopeResponse, error)
+	mustEmbedUnimplementedScopeServer()
+}
+
+// UnimplementedScopeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedScopeServer struct{}
+
+func (UnimplementedScopeServer) Match(grpc.BidiStreamingServer[MatchRequest, MatchResult]) error {
+	return status.Error(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedScopeServer) Check(context.Context, *MatchRequest) (*MatchResult, error) {
+	return nil, status.Error(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedScopeServer) ReloadScope(context.Context, *ReloadScopeRequest) (*ReloadScopeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ReloadScope not implemented")
+}
+func (UnimplementedScopeServer) mustEmbedUnimplementedScopeServer() {}
+func (UnimplementedScopeServer) testEmbeddedByValue()               {}
+
+// UnsafeScopeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScopeServer will
+// result in compilation errors.
+type UnsafeScopeServer interface {
+	mustEmbedUnimplementedScopeServer()
+}
+
+func RegisterScopeServer(s grpc.ServiceRegistrar, srv ScopeServer) {
+	// If the following call panics, it indicates UnimplementedScopeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Scope_ServiceDesc, srv)
+}
+
+func _Scope_Match_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ScopeServer).Match(&grpc.GenericServerStream[MatchRequest, MatchResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Scope_MatchServer = grpc.BidiStreamingServer[MatchRequest, MatchResult]
+
+func _Scope_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScopeServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scope_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScopeServer).Check(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scope_ReloadScope_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadScopeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScopeServer).ReloadScope(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scope_ReloadScope_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScopeServer).ReloadScope(ctx, req.(*ReloadScopeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Scope_ServiceDesc is the grpc.ServiceDesc for Scope service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Scope_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nscope.v1.Scope",
+	HandlerType: (*ScopeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _Scope_Check_Handler,
+		},
+		{
+			MethodName: "ReloadScope",
+			Handler:    _Scope_ReloadScope_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Match",
+			Handler:       _Scope_Match_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api/nscope.proto",
+}
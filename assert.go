@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runAssert(args []string, stderr io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("assert", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	listFile := fs.String("l", "", "file containing list of urls/domains (if empty read from stdin)")
+	maxViolations := fs.Int("max-violations", 20, "maximum number of violations to print (0 prints all)")
+	allowInvalid := fs.Bool("allow-invalid", false, "do not count lines without an extractable host as violations")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return false, fmt.Errorf("-s scope file is required")
+	}
+	scope, err := loadScopeFiles(*scopeFile, nscope.LoadOptions{})
+	if err != nil {
+		return false, fmt.Errorf("reading scope file: %w", err)
+	}
+
+	in := io.Reader(os.Stdin)
+	if *listFile != "" {
+		f, err := os.Open(*listFile)
+		if err != nil {
+			return false, fmt.Errorf("opening list file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	rc, err := nscope.Decompress(in)
+	if err != nil {
+		return false, fmt.Errorf("opening list file: %w", err)
+	}
+	defer rc.Close()
+	in = rc
+
+	violations := 0
+	st, err := nscope.ScanLines(in, scope, nscope.Options{MaxHostLength: nscope.DefaultMaxHostLength}, func(res *nscope.Result) error {
+		var reason string
+		switch {
+		case res.Skipped:
+			return nil
+		case res.Invalid:
+			if *allowInvalid {
+				return nil
+			}
+			reason = "invalid"
+		case res.Verdict == nscope.Included:
+			return nil
+		default:
+			reason = res.Verdict.String()
+		}
+		violations++
+		if *maxViolations <= 0 || violations <= *maxViolations {
+			fmt.Fprintf(stderr, "line %d: %s (%s)\n", res.LineNo, res.Line, reason)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("processing lines: %w", err)
+	}
+	if violations == 0 {
+		return true, nil
+	}
+	if *maxViolations > 0 && violations > *maxViolations {
+		fmt.Fprintf(stderr, "... %d more not shown\n", violations-*maxViolations)
+	}
+	fmt.Fprintf(stderr, "%d of %d targets out of scope\n", violations, st.Parsed+st.Invalid)
+	return false, nil
+}
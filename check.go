@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runCheck(args []string, w io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return false, fmt.Errorf("-s scope file is required")
+	}
+	if fs.NArg() == 0 {
+		return false, fmt.Errorf("usage: nscope check -s SCOPE TARGET...")
+	}
+	scope, err := loadScopeFiles(*scopeFile, nscope.LoadOptions{})
+	if err != nil {
+		return false, fmt.Errorf("reading scope file: %w", err)
+	}
+
+	all := true
+	opts := nscope.Options{MaxHostLength: nscope.DefaultMaxHostLength, Rules: true}
+	_, err = nscope.ScanLines(strings.NewReader(strings.Join(fs.Args(), "\n")), scope, opts, func(res *nscope.Result) error {
+		verdict := res.Verdict.String()
+		switch {
+		case res.Skipped:
+			return nil
+		case res.Invalid:
+			verdict = "invalid"
+		}
+		if res.Invalid || res.Verdict != nscope.Included {
+			all = false
+		}
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", res.Line, verdict, res.Rule.Describe())
+		return err
+	})
+	return all, err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runCompile(args []string) error {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	outFile := fs.String("o", "", "file to write the compiled scope to (required)")
+	compact := fs.Bool("compact-scope", false, "drop raw scope lines before compiling")
+	wildcardApex := fs.Bool("wildcard-apex", true, "let *.example.com also match example.com itself")
+	asnDB := fs.String("asn-db", "", "prefix-to-ASN table used to expand as: entries instead of querying RIPEstat")
+	fs.Parse(args)
+
+	if *scopeFile == "" || *outFile == "" {
+		return fmt.Errorf("usage: nscope compile -s SCOPE -o FILE")
+	}
+	var err error
+	lo := nscope.LoadOptions{Compact: *compact, WildcardSkipsApex: !*wildcardApex}
+	if *asnDB != "" {
+		if lo.ASN, err = nscope.LoadASNFile(*asnDB); err != nil {
+			return fmt.Errorf("reading ASN table: %w", err)
+		}
+	}
+	scope, err := loadScopeFiles(*scopeFile, lo)
+	if err != nil {
+		return fmt.Errorf("reading scope file: %w", err)
+	}
+	b, err := scope.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*outFile, b, 0o644)
+}
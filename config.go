@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDir returns nscope's configuration directory, ~/.config/nscope on
+// Linux.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nscope"), nil
+}
+
+// configGroups lists flags that replace each other: giving one of them on the
+// command line overrides config values for all, so a scope given with -s is
+// not merged with a default profile.
+var configGroups = [][]string{{"s", "S", "p", "d"}, {"o", "output", "nats-pub", "kafka-pub"}}
+
+// applyConfig sets the flags of fset named in the YAML config file at path
+// that were not given on the command line. Keys are flag names; a list sets a
+// repeatable flag once per item. A missing file is only an error when the
+// path was given explicitly.
+func applyConfig(fset *flag.FlagSet, path string, explicit bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	var doc yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	top := doc.Content[0]
+	if top.Tag == "!!null" {
+		return nil
+	}
+	if top.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: want a mapping of flag names to values", path, top.Line)
+	}
+	set := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, group := range configGroups {
+		if slices.ContainsFunc(group, func(name string) bool { return set[name] }) {
+			for _, name := range group {
+				set[name] = true
+			}
+		}
+	}
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		k, v := top.Content[i], top.Content[i+1]
+		fl := fset.Lookup(k.Value)
+		if fl == nil || fl.Name == "config" {
+			return fmt.Errorf("%s:%d: unknown flag %q", path, k.Line, k.Value)
+		}
+		if set[fl.Name] {
+			continue
+		}
+		var values []string
+		switch v.Kind {
+		case yaml.ScalarNode:
+			values = []string{v.Value}
+		case yaml.SequenceNode:
+			if _, ok := fl.Value.(*stringList); !ok {
+				return fmt.Errorf("%s:%d: -%s takes a single value", path, v.Line, fl.Name)
+			}
+			for _, item := range v.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("%s:%d: -%s: want a list of values", path, item.Line, fl.Name)
+				}
+				values = append(values, item.Value)
+			}
+		default:
+			return fmt.Errorf("%s:%d: -%s: want a value or a list of values", path, v.Line, fl.Name)
+		}
+		for _, s := range values {
+			if err := fl.Value.Set(s); err != nil {
+				return fmt.Errorf("%s:%d: -%s: %v", path, v.Line, fl.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runExport(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append([]string{"-format", args[0]}, args[1:]...)
+	}
+	format := fs.String("format", "json", "output format (json, burp, zap)")
+	name := fs.String("name", "nscope", "context name for -format zap")
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	var excludeFiles stringList
+	fs.Var(&excludeFiles, "x", "file containing out-of-scope entries; may be repeated")
+	asnDB := fs.String("asn-db", "", "prefix-to-ASN table used to expand as: entries instead of querying RIPEstat")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return fmt.Errorf("-s scope file is required")
+	}
+
+	var err error
+	var lo nscope.LoadOptions
+	if *asnDB != "" {
+		if lo.ASN, err = nscope.LoadASNFile(*asnDB); err != nil {
+			return fmt.Errorf("reading ASN table: %w", err)
+		}
+	}
+	scope, err := loadScopeFiles(*scopeFile, lo)
+	if err != nil {
+		return fmt.Errorf("reading scope file: %w", err)
+	}
+	for _, path := range excludeFiles {
+		x, err := loadScopeFiles(path, lo)
+		if err != nil {
+			return fmt.Errorf("reading exclude file: %w", err)
+		}
+		scope.AddExclusions(x)
+	}
+	var out []byte
+	switch *format {
+	case "json":
+		out, err = json.MarshalIndent(scope, "", "  ")
+	case "burp":
+		out, err = scope.MarshalBurp()
+	case "zap":
+		out, err = scope.MarshalZAP(*name)
+	default:
+		return fmt.Errorf("unsupported export format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func runImport(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nscope import burp|zap|bugcrowd|intigriti|csv FILE")
+	}
+	fs := flag.NewFlagSet("import "+args[0], flag.ExitOnError)
+	var parse func([]byte, string) (*nscope.Scope, error)
+	switch args[0] {
+	case "burp":
+		parse = nscope.ParseBurpScope
+	case "zap":
+		parse = nscope.ParseZAPContext
+	case "bugcrowd":
+		parse = nscope.ParseBugcrowdCSV
+	case "intigriti":
+		parse = nscope.ParseIntigritiCSV
+	case "csv":
+		hostCol := fs.String("host-col", "identifier", "column holding the host, URL or network")
+		typeCol := fs.String("type-col", "asset_type", "column holding the asset type (empty accepts every row)")
+		inScopeCol := fs.String("in-scope-col", "eligible_for_submission", "column whose true/false value marks rows in or out of scope")
+		subdomainsCol := fs.String("include-subdomains-col", "", "column whose true value extends a host to its subdomains")
+		parse = func(b []byte, path string) (*nscope.Scope, error) {
+			return nscope.ParseAssetCSV(b, path, nscope.CSVColumns{
+				Host:       columnNames(*hostCol),
+				Type:       columnNames(*typeCol),
+				InScope:    columnNames(*inScopeCol),
+				Subdomains: columnNames(*subdomainsCol),
+			})
+		}
+	default:
+		return fmt.Errorf("unsupported import format %q", args[0])
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: nscope import %s FILE", args[0])
+	}
+	path := fs.Arg(0)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scope, err := parse(b, path)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(scope, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func columnNames(name string) []string {
+	if name == "" {
+		return nil
+	}
+	return []string{name}
+}
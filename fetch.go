@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runFetch(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nscope fetch h1|bugcrowd|intigriti -program HANDLE")
+	}
+	fs := flag.NewFlagSet("fetch "+args[0], flag.ExitOnError)
+	program := fs.String("program", "", "program handle (required)")
+	fs.Parse(args[1:])
+	if *program == "" {
+		return fmt.Errorf("-program is required")
+	}
+	text, err := nscope.FetchScopeText(args[0], *program)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, text)
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// follower reads a file like tail -F: at end of file it waits for more data
+// instead of returning io.EOF, and when the file is truncated or replaced,
+// as log rotation does, it starts over from the beginning of the new file.
+// It reports io.EOF once ctx is done.
+type follower struct {
+	ctx      context.Context
+	path     string
+	interval time.Duration
+	f        *os.File
+	off      int64
+}
+
+func followFile(ctx context.Context, path string, interval time.Duration) (*follower, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &follower{ctx: ctx, path: path, interval: interval, f: f}, nil
+}
+
+func (fl *follower) Read(p []byte) (int, error) {
+	for {
+		if fl.f == nil {
+			if f, err := os.Open(fl.path); err == nil {
+				fl.f, fl.off = f, 0
+				continue
+			}
+		} else {
+			n, err := fl.f.Read(p)
+			fl.off += int64(n)
+			if n > 0 || (err != nil && err != io.EOF) {
+				return n, err
+			}
+			cur, cerr := fl.f.Stat()
+			fi, ferr := os.Stat(fl.path)
+			switch {
+			case cerr != nil || ferr != nil:
+				// The file was moved away; keep the old one until a new one
+				// appears, since the writer may still be appending to it.
+			case !os.SameFile(cur, fi):
+				fl.f.Close()
+				fl.f = nil
+				continue
+			case fi.Size() < fl.off:
+				if _, err := fl.f.Seek(0, io.SeekStart); err != nil {
+					return 0, err
+				}
+				fl.off = 0
+				continue
+			}
+		}
+		select {
+		case <-fl.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(fl.interval):
+		}
+	}
+}
+
+func (fl *follower) Close() error {
+	if fl.f == nil {
+		return nil
+	}
+	return fl.f.Close()
+}
@@ -1,7 +1,20 @@
 module github.com/nlxz/nscope
 
-go 1.24
+go 1.24.0
 
-require golang.org/x/net v0.44.0
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/segmentio/kafka-go v0.4.49
+	golang.org/x/net v0.44.0
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require golang.org/x/text v0.29.0 // indirect
\ No newline at end of file
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+)
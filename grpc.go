@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nlxz/nscope/api/nscopev1"
+)
+
+//go:generate protoc --go_out=. --go_opt=module=github.com/nlxz/nscope --go-grpc_out=. --go-grpc_opt=module=github.com/nlxz/nscope api/nscope.proto
+
+// grpcScope implements the Scope service of api/nscope.proto on top of a
+// scopeServer, so gRPC queries share its scope, reloads and metrics.
+type grpcScope struct {
+	nscopev1.UnimplementedScopeServer
+	s *scopeServer
+}
+
+func (s *scopeServer) grpcServer() *grpc.Server {
+	srv := grpc.NewServer()
+	nscopev1.RegisterScopeServer(srv, &grpcScope{s: s})
+	return srv
+}
+
+func (g *grpcScope) Match(stream grpc.BidiStreamingServer[nscopev1.MatchRequest, nscopev1.MatchResult]) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(g.result(req.GetTarget())); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *grpcScope) Check(ctx context.Context, req *nscopev1.MatchRequest) (*nscopev1.MatchResult, error) {
+	return g.result(req.GetTarget()), nil
+}
+
+func (g *grpcScope) ReloadScope(ctx context.Context, req *nscopev1.ReloadScopeRequest) (*nscopev1.ReloadScopeResponse, error) {
+	if err := g.s.reload(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &nscopev1.ReloadScopeResponse{Rules: int32(g.s.scope.Load().MemStats().Entries)}, nil
+}
+
+func (g *grpcScope) result(target string) *nscopev1.MatchResult {
+	r := g.s.matchTarget(target)
+	return &nscopev1.MatchResult{
+		Input:     r.Input,
+		Host:      r.Host,
+		Port:      r.Port,
+		Verdict:   r.Verdict,
+		Matched:   r.Matched,
+		Rule:      r.Rule,
+		RuleIndex: int32(r.RuleIndex),
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+// expandPaths splits each argument on commas and expands glob patterns.
+// With dirs set, a directory stands for the regular files below it, walked
+// recursively in lexical order and skipping hidden files and directories.
+func expandPaths(args []string, dirs bool) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		for _, p := range strings.Split(arg, ",") {
+			if p == "" {
+				continue
+			}
+			matches := []string{p}
+			if strings.Contains(p, "://") {
+				paths = append(paths, p)
+				continue
+			}
+			if strings.ContainsAny(p, "*?[") {
+				var err error
+				if matches, err = filepath.Glob(p); err != nil {
+					return nil, err
+				}
+				if len(matches) == 0 {
+					return nil, fmt.Errorf("no files match %s", p)
+				}
+			}
+			for _, m := range matches {
+				fi, err := os.Stat(m)
+				if err != nil || !dirs || !fi.IsDir() {
+					paths = append(paths, m)
+					continue
+				}
+				err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if path != m && strings.HasPrefix(d.Name(), ".") {
+						if d.IsDir() {
+							return filepath.SkipDir
+						}
+						return nil
+					}
+					if d.Type().IsRegular() {
+						paths = append(paths, path)
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+// loadScopeFiles loads every scope file named by spec and merges them.
+func loadScopeFiles(spec string, lo nscope.LoadOptions) (*nscope.Scope, error) {
+	paths, err := expandPaths([]string{spec}, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no scope file in %q", spec)
+	}
+	m, err := loadScopeFile(paths[0], lo)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths[1:] {
+		o, err := loadScopeFile(p, lo)
+		if err != nil {
+			return nil, err
+		}
+		m.Merge(o)
+	}
+	return m, nil
+}
+
+func loadScopeFile(path string, lo nscope.LoadOptions) (*nscope.Scope, error) {
+	if path != "-" {
+		return nscope.LoadScope(path, lo)
+	}
+	r, err := nscope.Decompress(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return nscope.ReadScope(r, "stdin", lo)
+}
+
+func readsStdin(specs []string) bool {
+	for _, spec := range specs {
+		if _, path, ok := strings.Cut(spec, "="); ok {
+			spec = path
+		}
+		if slices.Contains(strings.Split(spec, ","), "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// fileChain reads several list files one after another, decompressing each
+// and separating them with sep so the last line of one file never runs into
+// the first line of the next. Files are opened only when reached.
+type fileChain struct {
+	paths   []string
+	sep     []byte
+	f       *os.File
+	cur     io.ReadCloser
+	pending []byte
+
+	mu     sync.Mutex
+	lines  int
+	starts []fileStart
+}
+
+// fileStart records the input line number at which a file begins.
+type fileStart struct {
+	name string
+	line int
+}
+
+func openFileChain(paths []string, sep string) (*fileChain, error) {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return nil, err
+		}
+	}
+	return &fileChain{paths: paths, sep: []byte(sep)}, nil
+}
+
+func (c *fileChain) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.pending) > 0 {
+				n := copy(p, c.pending)
+				c.pending = c.pending[n:]
+				c.count(p[:n])
+				return n, nil
+			}
+			if len(c.paths) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(c.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			r, err := nscope.Decompress(f)
+			if err != nil {
+				f.Close()
+				return 0, err
+			}
+			c.mu.Lock()
+			c.starts = append(c.starts, fileStart{name: c.paths[0], line: c.lines + 1})
+			c.mu.Unlock()
+			c.paths = c.paths[1:]
+			c.f, c.cur = f, r
+		}
+		n, err := c.cur.Read(p)
+		c.count(p[:n])
+		if err == io.EOF {
+			c.Close()
+			if len(c.paths) > 0 {
+				c.pending = c.sep
+			}
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// count advances the line count past the record terminators in p.
+func (c *fileChain) count(p []byte) {
+	n := bytes.Count(p, c.sep[len(c.sep)-1:])
+	c.mu.Lock()
+	c.lines += n
+	c.mu.Unlock()
+}
+
+// locate maps a line number of the chained input to the file it came from
+// and its line number within that file.
+func (c *fileChain) locate(lineNo int) (string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := sort.Search(len(c.starts), func(i int) bool { return c.starts[i].line > lineNo }) - 1
+	if i < 0 {
+		return "", lineNo
+	}
+	return c.starts[i].name, lineNo - c.starts[i].line + 1
+}
+
+func (c *fileChain) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	c.cur.Close()
+	err := c.f.Close()
+	c.f, c.cur = nil, nil
+	return err
+}
+
+func parseHeaders(lines []string) (http.Header, error) {
+	h := make(http.Header)
+	for _, l := range lines {
+		name, value, ok := strings.Cut(l, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q (want Name: value)", l)
+		}
+		h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+func scopeCacheDir(flagValue string) string {
+	switch flagValue {
+	case "none":
+		return ""
+	case "":
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(dir, "nscope", "scopes")
+	}
+	return flagValue
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBrokers splits the -kafka flag into broker addresses.
+func kafkaBrokers(spec string) []string {
+	var brokers []string
+	for _, b := range strings.Split(spec, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+// streamKafka reads the messages of topic as lines, reconnecting when the
+// brokers go away. With a group the partitions are shared with the other
+// members and offsets are committed as messages are read; without one only
+// partition 0 is read, from the newest message on.
+func streamKafka(ctx context.Context, brokers, topic, group string, warn io.Writer) io.ReadCloser {
+	relay := func(ctx context.Context, w io.Writer) (int, error) {
+		cfg := kafka.ReaderConfig{
+			Brokers: kafkaBrokers(brokers),
+			Topic:   topic,
+			GroupID: group,
+			ErrorLogger: kafka.LoggerFunc(func(format string, args ...any) {
+				fmt.Fprintf(warn, "kafka: "+format+"\n", args...)
+			}),
+		}
+		if group == "" {
+			cfg.StartOffset = kafka.LastOffset
+		}
+		r := kafka.NewReader(cfg)
+		defer r.Close()
+		for n := 0; ; n++ {
+			m, err := r.ReadMessage(ctx)
+			if err != nil {
+				return n, err
+			}
+			if err := writeMessage(w, m.Value); err != nil {
+				return n, err
+			}
+		}
+	}
+	return reconnectingStream(ctx, "kafka", relay, warn)
+}
+
+// kafkaPublisher produces every line written to it as a message to a topic.
+// The lines of one Write are sent as one batch.
+type kafkaPublisher struct {
+	w       *kafka.Writer
+	partial []byte
+}
+
+func publishKafka(brokers, topic string) *kafkaPublisher {
+	return &kafkaPublisher{w: &kafka.Writer{
+		Addr:         kafka.TCP(kafkaBrokers(brokers)...),
+		Topic:        topic,
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (p *kafkaPublisher) Write(b []byte) (int, error) {
+	n := len(b)
+	var msgs []kafka.Message
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i == -1 {
+			p.partial = append(p.partial, b...)
+			break
+		}
+		msgs = append(msgs, kafka.Message{Value: append(p.partial, b[:i]...)})
+		p.partial = nil
+		b = b[i+1:]
+	}
+	if len(msgs) > 0 {
+		if err := p.w.WriteMessages(context.Background(), msgs...); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close produces an unterminated last line and flushes the writer.
+func (p *kafkaPublisher) Close() error {
+	if len(p.partial) > 0 {
+		if err := p.w.WriteMessages(context.Background(), kafka.Message{Value: p.partial}); err != nil {
+			p.w.Close()
+			return err
+		}
+		p.partial = nil
+	}
+	return p.w.Close()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runLint(args []string, w io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required); comma-separate or use globs to lint several together")
+	jsonOut := fs.Bool("json", false, "write one JSON object per issue")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return false, fmt.Errorf("-s scope file is required")
+	}
+	paths, err := expandPaths([]string{*scopeFile}, true)
+	if err != nil {
+		return false, err
+	}
+	if len(paths) == 0 {
+		return false, fmt.Errorf("no scope file in %q", *scopeFile)
+	}
+	var l nscope.Linter
+	for _, path := range paths {
+		if err := lintFile(&l, path); err != nil {
+			return false, fmt.Errorf("reading scope file: %w", err)
+		}
+	}
+	issues := l.Issues()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, is := range issues {
+		if *jsonOut {
+			err = enc.Encode(is)
+		} else {
+			_, err = fmt.Fprintln(w, is)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return len(issues) == 0, nil
+}
+
+func lintFile(l *nscope.Linter, path string) error {
+	in := io.Reader(os.Stdin)
+	name := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in, name = f, path
+	}
+	r, err := nscope.Decompress(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return l.ReadScope(r, name)
+}
@@ -1,402 +1,790 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
-	"net"
-	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"golang.org/x/net/idna"
+	"github.com/nlxz/nscope/pkg/nscope"
 )
 
-type scopeKind int
-
-const (
-	scopeExact scopeKind = iota
-	scopeLeadingWildcard
-	scopePatternWildcard
-)
-
-type scopeEntry struct {
-	raw           string
-	kind          scopeKind
-	base          string
-	port          string
-	patternLabels []string
-}
-
 func main() {
-	listFile := flag.String("l", "", "file containing list of urls/domains (if empty read from stdin)")
-	scopeFile := flag.String("s", "", "file containing scope domains (required)")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			if err := runExport(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "compile":
+			if err := runCompile(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "fetch":
+			if err := runFetch(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "merge":
+			if err := runMerge(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "normalize":
+			if err := runNormalize(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "import":
+			if err := runImport(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "profiles":
+			if err := runProfiles(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		case "check":
+			ok, err := runCheck(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		case "lint":
+			ok, err := runLint(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		case "test":
+			ok, err := runTest(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		case "assert":
+			ok, err := runAssert(os.Args[2:], os.Stderr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(2)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var listFiles stringList
+	flag.Var(&listFiles, "l", "file containing list of urls/domains (if empty read from stdin); repeat, comma-separate or use globs to read several")
+	var scopeFiles stringList
+	flag.Var(&scopeFiles, "s", "file containing scope domains (required); repeat, comma-separate or use globs to merge files, or repeat as name=path to match several scopes at once")
+	configFile := flag.String("config", "", "YAML file of default flag values, overridden by flags given on the command line (default ~/.config/nscope/config.yaml)")
+	var profiles stringList
+	flag.Var(&profiles, "p", "named scope profile from ~/.config/nscope/scopes (see nscope profiles); used like -s")
+	var inlineScope stringList
+	var scopeHeaders stringList
+	flag.Var(&scopeHeaders, "scope-header", "HTTP header sent when -s is an http(s) URL, e.g. 'Authorization: Bearer TOKEN'; repeat for more")
+	scopeCache := flag.String("scope-cache", "", "directory caching http(s) scope files, revalidated by ETag (default under the user cache directory; none disables)")
+	flag.Var(&inlineScope, "d", "scope entry given on the command line, e.g. -d '*.example.com'; repeat for more")
+	flag.Var(&scopeFiles, "S", "compiled scope file written by nscope compile; used like -s")
+	var excludeFiles stringList
+	flag.Var(&excludeFiles, "x", "file containing out-of-scope entries; may be repeated")
 	reverse := flag.Bool("r", false, "print lines that do not match scope")
+	maxHostLength := flag.Int("max-host-length", nscope.DefaultMaxHostLength, "treat hosts longer than this as unparseable (0 disables the limit)")
+	resolve := flag.Bool("resolve", false, "resolve hostnames (A/AAAA) and match them through their IPs when the name itself matches nothing")
+	rdns := flag.Bool("rdns", false, "look up PTR names of IP inputs and match them against domain entries when the IP itself matches nothing")
+	resolvers := flag.String("resolvers", "system", "DNS servers for -resolve and -rdns: system, IP[:port] or a DoH https:// URL, comma-separated, or a file listing one per line")
+	doh := flag.String("doh", "", "DNS-over-HTTPS endpoint(s) for -resolve and -rdns, comma-separated; used instead of the system resolver")
+	dnsConcurrency := flag.Int("dns-concurrency", 0, "maximum DNS queries in flight across -resolve and -rdns (default 4 per -t worker)")
+	withIP := flag.Bool("with-ip", false, "also match the bracketed IP column (httpx -ip style), or the ip/a field with -json-input")
+	matchPolicy := flag.String("match", "any", "how host and IP verdicts combine with -with-ip (any, all)")
+	annotate := flag.Bool("annotate", false, "append which value matched (host, ip) to printed lines")
+	explainRule := flag.Bool("explain", false, "append the scope entry that kept or dropped each printed line")
+	workers := flag.Int("t", 1, "number of worker goroutines matching lines in parallel")
+	unordered := flag.Bool("unordered", false, "with -t, print lines as soon as they are matched instead of in input order")
+	asnDB := flag.String("asn-db", "", "prefix-to-ASN table (PREFIX ASN or START END ASN per line) used to expand as: entries instead of querying RIPEstat")
+	rejectSuffix := flag.Bool("reject-public-suffix", false, "refuse to load scope entries that are bare public suffixes such as *.com or co.uk")
+	wildcardApex := flag.Bool("wildcard-apex", true, "let *.example.com also match example.com itself; -wildcard-apex=false matches subdomains only")
+	strictIDNA := flag.Bool("strict-idna", false, "reject and report hosts (in input and scope) that fail IDNA/UTS-46 validation instead of passing them through")
+	compact := flag.Bool("compact-scope", false, "drop raw scope lines and intern strings to reduce memory on very large scopes")
+	unusedRules := flag.Bool("unused-rules", false, "list scope entries that matched no input line on stderr when done")
+	limit := flag.Int("limit", 0, "stop reading input once this many lines have been printed (0 means no limit)")
+	quiet := flag.Bool("q", false, "print nothing; exit 0 if any line would be printed, 1 if none, 2 on error")
+	stats := flag.Bool("stats", false, "print line counts, elapsed time and throughput to stderr when done")
+	memStats := flag.Bool("mem-stats", false, "print scope entry counts and approximate memory usage to stderr")
+	inFile := flag.String("in-file", "", "also write in-scope lines to this file")
+	outOfScopeFile := flag.String("out-file", "", "also write out-of-scope lines to this file")
+	excludedOut := flag.String("excluded-out", "", "also write lines rejected by an exclusion to this file")
+	unmatchedOut := flag.String("unmatched-out", "", "also write lines that matched no scope entry to this file")
+	rejectsOut := flag.String("rejects", "", "also write lines that were skipped or had no extractable host to this file, with the line number and the reason")
+	explain := flag.Bool("explain-scope", false, "print every scope entry as written and as matched, then exit")
+	notices := flag.Bool("scope-notices", false, "report scope entries changed by normalization or IDNA failures on stderr")
+	rulePriority := flag.String("rule-priority", "first", "which entry is reported when several match (first, specific)")
+	outputFormat := flag.String("output-format", "plain", "output format (plain, json, csv, count, etld1)")
+	formatTemplate := flag.String("format", "", "write each printed line with this Go template, e.g. '{{.Host}}:{{.Port}} {{.Rule.Raw}}'")
+	etld1 := flag.Bool("etld1", false, "print how many lines fall under each registrable domain (same as -output-format etld1)")
+	countOnly := flag.Bool("c", false, "print only the number of lines that would be printed (same as -output-format count)")
+	jsonOutput := flag.Bool("json", false, "write one JSON object per line with the matching rule (same as -output-format json)")
+	sqlitePath := flag.String("sqlite", "", "also upsert the hosts of printed lines into this SQLite database (needs the sqlite3 command)")
+	outFile := flag.String("o", "", "file to write output to (default stdout)")
+	flag.StringVar(outFile, "output", "", "same as -o")
+	compress := flag.String("compress", "", "compress output with gzip or zstd (default from the -o extension: .gz or .zst)")
+	outDir := flag.String("out-dir", "", "with named scopes, write each scope's lines to DIR/NAME.txt")
+	watchDir := flag.String("watch-dir", "", "process every file created in this directory until interrupted")
+	watchState := flag.String("watch-state", "", "file recording files already processed by -watch-dir (default DIR/.nscope-processed)")
+	watchInterval := flag.Duration("watch-interval", 2*time.Second, "how often -watch-dir checks for new files")
+	follow := flag.Bool("f", false, "keep reading the -l file as it grows, like tail -F, surviving truncation and rotation")
+	wsURL := flag.String("ws", "", "read messages from this WebSocket feed, e.g. wss://certstream.calidog.io/ with -if certstream, reconnecting when it drops")
+	natsServer := flag.String("nats", "nats://localhost:4222", "NATS server for -nats-sub and -nats-pub (nats:// or tls://, optionally with user:pass@ or token@)")
+	natsSub := flag.String("nats-sub", "", "read input from the messages published to this NATS subject, one line each")
+	natsPub := flag.String("nats-pub", "", "publish each output line as a message to this NATS subject instead of writing it to stdout")
+	kafkaServer := flag.String("kafka", "localhost:9092", "Kafka brokers for -kafka-sub and -kafka-pub, comma-separated")
+	kafkaSub := flag.String("kafka-sub", "", "read input from the messages of this Kafka topic, one line each")
+	kafkaGroup := flag.String("kafka-group", "nscope", "consumer group for -kafka-sub, sharing partitions and committing offsets; empty reads partition 0 from the newest message")
+	kafkaPub := flag.String("kafka-pub", "", "produce each output line as a message to this Kafka topic instead of writing it to stdout")
+	reload := flag.Bool("reload-scope", false, "reload the scope and -x files when they change, without interrupting the input stream")
+	reloadInterval := flag.Duration("reload-interval", 2*time.Second, "how often -reload-scope checks the scope files where they cannot be watched")
+	refang := flag.Bool("refang", false, "undo defanging such as example[.]com and hxxp:// before extracting the host")
+	grep := flag.Bool("grep", false, "find every host, IP and URL anywhere in the line and keep the line if any is in scope")
+	onlyHosts := flag.Bool("only-hosts", false, "print the matched hosts instead of whole lines (one per line)")
+	flag.BoolVar(onlyHosts, "host-only", false, "same as -only-hosts")
+	flag.BoolVar(onlyHosts, "oh", false, "same as -only-hosts")
+	nulSeparated := flag.Bool("0", false, "read and write NUL-terminated records instead of lines (for xargs -0 and find -print0)")
+	unique := flag.Bool("u", false, "suppress duplicate output lines")
+	sortOutput := flag.Bool("sort", false, "sort output lines; large outputs are sorted in temporary files")
+	stateFile := flag.String("state", "", "remember hashes of output lines in this file across runs")
+	newOnly := flag.Bool("new-only", false, "with -state, print only lines not printed by earlier runs")
+	noColor := flag.Bool("no-color", false, "do not color output, which is otherwise highlighted when stdout is a terminal and NO_COLOR is unset")
+	lineNumbers := flag.Bool("n", false, "prefix printed lines with their line number, and with the file name (file:line:) when several -l files are read")
+	withPort := flag.Bool("with-port", false, "with -only-hosts, print host:port when the input has a port")
+	delim := flag.String("delim", "", "field delimiter for -field, e.g. , or \\t (default whitespace); quoted CSV fields are understood")
+	field := flag.Int("field", 0, "take the host from this 1-based field instead of the first")
+	inputFormat := flag.String("if", "lines", "input format (lines, httpx, amass, nmap, masscan, massdns, certstream, har, burp, http, pcap, zeek)")
+	urlsOnly := flag.Bool("urls", false, "with -if har, burp or http, print the matching request URLs instead of a filtered export")
+	nmapServices := flag.Bool("nmap-services", false, "with -if nmap, print host:port for each in-scope open port instead of filtered XML")
+	jsonInput := flag.Bool("json-input", false, "read JSON Lines and match the host or URL found at -host-field; records are printed unchanged")
+	hostField := flag.String("host-field", ".host", "dotted path of the host or URL in -json-input records, e.g. .request.url")
+	prefixStrip := flag.String("prefix-strip", "", "remove this prefix from each input line before extracting the host")
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  nscope [flags]\n\nFlags:\n  -l string \tfile containing list of urls/domains (if empty read from stdin)\n  -s string \tfile containing scope domains (required)\n  -r \t\tprint lines that do not match scope\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  nscope [flags]\n\nFlags:\n")
+		flag.PrintDefaults()
 	}
 	flag.Parse()
 
-	if *scopeFile == "" {
-		fmt.Fprintln(os.Stderr, "error: -s scope file is required")
-		os.Exit(1)
+	configPath := *configFile
+	if configPath == "" {
+		if dir, err := configDir(); err == nil {
+			configPath = filepath.Join(dir, "config.yaml")
+		}
 	}
-
-	scope, err := loadScope(*scopeFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error reading scope file: %v\n", err)
-		os.Exit(1)
+	if configPath != "" {
+		if err := applyConfig(flag.CommandLine, configPath, *configFile != ""); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading config: %v\n", err)
+			os.Exit(2)
+		}
 	}
-
-	var in io.Reader
-	if *listFile == "" {
-		in = os.Stdin
-	} else {
-		f, err := os.Open(*listFile)
+	for _, name := range profiles {
+		path, err := profilePath(name)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error opening list file: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
 		}
-		defer f.Close()
-		in = f
+		scopeFiles = append(scopeFiles, path)
 	}
-
-	if err := processLines(in, os.Stdout, scope, *reverse); err != nil {
-		fmt.Fprintf(os.Stderr, "error processing lines: %v\n", err)
-		os.Exit(1)
+	if len(scopeFiles) == 0 && len(inlineScope) == 0 {
+		fmt.Fprintln(os.Stderr, "error: -s scope file (or -S compiled scope, -p profile, or -d entries) is required")
+		os.Exit(2)
 	}
-}
-
-func loadScope(path string) ([]scopeEntry, error) {
-	f, err := os.Open(path)
+	if readsStdin(scopeFiles) && len(listFiles) == 0 && *watchDir == "" {
+		fmt.Fprintln(os.Stderr, "error: the scope is read from stdin (-s -), so give the input with -l")
+		os.Exit(2)
+	}
+	scopes, multi, err := parseScopeArgs(scopeFiles)
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
 	}
-	defer f.Close()
-
-	var out []scopeEntry
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		raw := sc.Text()
-		trimmed := strings.TrimSpace(raw)
-		if trimmed == "" {
-			continue
-		}
-		if strings.HasPrefix(trimmed, "#") {
-			continue
+	if len(inlineScope) > 0 {
+		if multi {
+			fmt.Fprintln(os.Stderr, "error: -d cannot be combined with named scopes")
+			os.Exit(2)
 		}
-		if idx := strings.Index(trimmed, "#"); idx != -1 {
-			trimmed = strings.TrimSpace(trimmed[:idx])
+		if len(scopes) == 0 {
+			scopes = append(scopes, &namedScope{name: "-d"})
 		}
-		if trimmed == "" {
-			continue
-		}
-		ent := parseScopeLine(trimmed)
-		out = append(out, ent)
 	}
-	if err := sc.Err(); err != nil {
-		return nil, err
+	priority, err := nscope.ParseRulePriority(*rulePriority)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	keepRaw := *outputFormat == "json" || *formatTemplate != "" || *explainRule || *explain || *unusedRules || *sqlitePath != ""
+	lo := nscope.LoadOptions{Compact: *compact, KeepRaw: keepRaw, RejectPublicSuffix: *rejectSuffix, WildcardSkipsApex: !*wildcardApex, StrictIDNA: *strictIDNA, CacheDir: scopeCacheDir(*scopeCache)}
+	if lo.Header, err = parseHeaders(scopeHeaders); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if *asnDB != "" {
+		if lo.ASN, err = nscope.LoadASNFile(*asnDB); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading ASN table: %v\n", err)
+			os.Exit(2)
+		}
 	}
-	return out, nil
-}
-
-func parseScopeLine(line string) scopeEntry {
-	orig := line
-	line = strings.TrimSpace(line)
-	line = strings.TrimSuffix(line, ".")
-	if strings.HasPrefix(line, "*.") {
-		without := strings.TrimPrefix(line, "*.")
-		host, port := stripPort(without)
-		host = strings.TrimSuffix(host, ".")
-		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
-			host = stripBrackets(host)
-		}
-		if ascii, err := idna.ToASCII(host); err == nil {
-			host = ascii
-		}
-		host = strings.ToLower(host)
-		return scopeEntry{raw: orig, kind: scopeLeadingWildcard, base: host, port: port}
-	}
-	if strings.Contains(line, "*") {
-		labels := strings.Split(line, ".")
-		for i := range labels {
-			lbl := strings.TrimSpace(labels[i])
-			if lbl == "" {
-				labels[i] = lbl
-				continue
+	buildScope := func(path string) (*nscope.Scope, error) {
+		var m *nscope.Scope
+		var err error
+		if len(inlineScope) > 0 {
+			if m, err = nscope.ReadScope(strings.NewReader(strings.Join(inlineScope, "\n")), "-d", lo); err != nil {
+				return nil, fmt.Errorf("in -d scope: %w", err)
 			}
-			if strings.Contains(lbl, ":") {
-				h, p := stripPort(lbl)
-				h = strings.TrimSuffix(h, ".")
-				if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
-					h = stripBrackets(h)
-				}
-				if ascii, err := idna.ToASCII(h); err == nil {
-					h = ascii
-				}
-				labels[i] = h
-				if p != "" {
-					labels = append(labels, "__PORT__:"+p)
-				}
-				continue
+		}
+		if path != "" {
+			fm, err := loadScopeFiles(path, lo)
+			if err != nil {
+				return nil, fmt.Errorf("reading scope file: %w", err)
 			}
-			if lbl != "*" {
-				if ascii, err := idna.ToASCII(lbl); err == nil {
-					lbl = ascii
-				}
+			if m != nil {
+				fm.Merge(m)
 			}
-			labels[i] = strings.ToLower(lbl)
-		}
-		var port string
-		if len(labels) > 0 {
-			last := labels[len(labels)-1]
-			if strings.HasPrefix(last, "__PORT__:") {
-				port = strings.TrimPrefix(last, "__PORT__:")
-				labels = labels[:len(labels)-1]
+			m = fm
+		}
+		for _, path := range excludeFiles {
+			x, err := loadScopeFiles(path, lo)
+			if err != nil {
+				return nil, fmt.Errorf("reading exclude file: %w", err)
 			}
+			m.AddExclusions(x)
 		}
-		return scopeEntry{raw: orig, kind: scopePatternWildcard, patternLabels: labels, port: port}
+		m.SetRulePriority(priority)
+		return m, nil
 	}
-
-	host, port := stripPort(line)
-	host = strings.TrimSuffix(host, ".")
-	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
-		host = stripBrackets(host)
+	for _, sc := range scopes {
+		if sc.m, err = buildScope(sc.path); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
 	}
-	if ip := net.ParseIP(host); ip != nil {
-		return scopeEntry{raw: orig, kind: scopeExact, base: ip.String(), port: port}
+	for _, sc := range scopes {
+		for _, r := range sc.m.PublicSuffixEntries() {
+			fmt.Fprintf(os.Stderr, "warning: %s covers a whole public suffix\n", r.Describe())
+		}
 	}
-	if ascii, err := idna.ToASCII(host); err == nil {
-		host = ascii
+	scope := scopes[0].m
+	if *explain {
+		scope.Explain(os.Stdout)
+		return
+	}
+	if *notices {
+		scope.WriteNotices(os.Stderr)
+	}
+	if *memStats {
+		st := scope.MemStats()
+		fmt.Fprintf(os.Stderr, "scope entries: %d (%s), approx %d bytes\n", st.Entries, st.Kinds(), st.Bytes)
 	}
-	host = strings.ToLower(host)
-	return scopeEntry{raw: orig, kind: scopeExact, base: host, port: port}
-}
 
-func processLines(r io.Reader, w io.Writer, scope []scopeEntry, reverse bool) error {
-	scanner := bufio.NewScanner(r)
-	const maxCapacity = 16 * 1024 * 1024
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, maxCapacity)
-	for scanner.Scan() {
-		line := scanner.Text()
-		host, port, ok := extractHostFromLine(line)
-		if !ok {
-			continue
-		}
-		normHost, err := normalizeHost(host)
-		if err != nil || normHost == "" {
-			continue
-		}
-		matched := matchHost(normHost, port, scope)
-		if matched && !reverse {
-			fmt.Fprintln(w, line)
-		}
-		if !matched && reverse {
-			fmt.Fprintln(w, line)
-		}
-	}
-	return scanner.Err()
-}
+	if *matchPolicy != "any" && *matchPolicy != "all" {
+		fmt.Fprintf(os.Stderr, "error: unknown -match policy %q\n", *matchPolicy)
+		os.Exit(2)
+	}
 
-func extractHostFromLine(line string) (string, string, bool) {
-	line = strings.TrimSpace(line)
-	if line == "" || strings.HasPrefix(line, "#") {
-		return "", "", false
-	}
-	fields := strings.Fields(line)
-	if len(fields) == 0 {
-		return "", "", false
-	}
-	first := fields[0]
-	if strings.Contains(first, "://") {
-		u, err := url.Parse(first)
+	if *jsonOutput {
+		*outputFormat = "json"
+	}
+	if *countOnly {
+		*outputFormat = "count"
+	}
+	if *etld1 {
+		*outputFormat = "etld1"
+	}
+	opts := nscope.Options{
+		Reverse:       *reverse,
+		MaxHostLength: *maxHostLength,
+		WithIP:        *withIP,
+		MatchAll:      *matchPolicy == "all",
+		Annotate:      *annotate,
+		Rules:         *outputFormat == "json" || *formatTemplate != "",
+		Explain:       *explainRule,
+		TrackUsage:    *unusedRules,
+		Workers:       *workers,
+		Unordered:     *unordered,
+		StrictIDNA:    *strictIDNA,
+		Refang:        *refang,
+		Grep:          *grep,
+		Delimiter:     strings.ReplaceAll(*delim, `\t`, "\t"),
+		Field:         *field,
+		OnlyMatching:  *onlyHosts,
+		WithPort:      *withPort,
+		NulSeparated:  *nulSeparated,
+		LineNumbers:   *lineNumbers,
+		Limit:         *limit,
+		Warnings:      os.Stderr,
+	}
+	if multi {
+		for _, sc := range scopes {
+			opts.Scopes = append(opts.Scopes, nscope.NamedScope{Name: sc.name, Scope: sc.m})
+		}
+	}
+	if !*noColor && os.Getenv("NO_COLOR") == "" && *outputFormat == "plain" && *formatTemplate == "" && *outFile == "" && *natsPub == "" && *kafkaPub == "" && !*quiet && !*nulSeparated && isTerminal(os.Stdout) {
+		opts.Color, opts.Rules = true, true
+	}
+	if *resolve || *rdns {
+		opts.Resolve, opts.ReverseDNS = *resolve, *rdns
+		spec, err := resolverSpec(*resolvers, *doh)
 		if err != nil {
-			return "", "", false
+			fmt.Fprintf(os.Stderr, "error reading resolvers: %v\n", err)
+			os.Exit(2)
 		}
-		if u.Host == "" {
-			return "", "", false
+		inflight := *dnsConcurrency
+		if inflight <= 0 {
+			inflight = 4 * max(*workers, 1)
 		}
-		h, p := stripPort(u.Host)
-		if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
-			h = stripBrackets(h)
+		if opts.Resolver, err = nscope.NewResolver(spec, inflight, 100000); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
 		}
-		return h, p, true
 	}
-	if strings.HasPrefix(first, "[") && strings.Contains(first, "]") {
-		h, p := stripPort(first)
-		if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
-			h = stripBrackets(h)
+	if *reload {
+		if multi {
+			fmt.Fprintln(os.Stderr, "error: -reload-scope works with a single scope")
+			os.Exit(2)
 		}
-		return h, p, true
+		opts.LiveScope = new(atomic.Pointer[nscope.Scope])
+		opts.LiveScope.Store(scope)
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		specs := append(append([]string{}, scopeFiles...), excludeFiles...)
+		go reloadScope(ctx, specs, *reloadInterval, func() (*nscope.Scope, error) {
+			return buildScope(scopes[0].path)
+		}, opts.LiveScope, os.Stderr)
+	}
+	// The -l files are opened later; sources is set then if there are several.
+	var sources *fileChain
+	if *lineNumbers {
+		opts.LineSource = func(lineNo int) (string, int) {
+			if sources == nil {
+				return "", lineNo
+			}
+			return sources.locate(lineNo)
+		}
+	}
+	if *formatTemplate != "" && *outputFormat != "plain" {
+		fmt.Fprintln(os.Stderr, "error: -format cannot be combined with -output-format, -json, -c or -etld1")
+		os.Exit(2)
 	}
-	if strings.Contains(first, "/") {
-		u, err := url.Parse("http://" + first)
-		if err == nil && u.Host != "" {
-			h, p := stripPort(u.Host)
-			if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
-				h = stripBrackets(h)
+	newEncoder := func() (nscope.OutputEncoder, error) {
+		if *formatTemplate != "" {
+			enc, err := nscope.NewTemplateEncoder(*formatTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("in -format template: %w", err)
 			}
-			return h, p, true
+			return enc, nil
+		}
+		return nscope.NewEncoder(*outputFormat, opts)
+	}
+	if opts.Encoder, err = newEncoder(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if *jsonInput {
+		opts.JSONField = *hostField
+	}
+	switch *inputFormat {
+	case "lines":
+	case "httpx", "amass", "nmap", "masscan", "massdns", "certstream", "har", "burp", "http", "pcap", "zeek":
+		opts.Input = *inputFormat
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown input format %q\n", *inputFormat)
+		os.Exit(2)
+	}
+	if *limit != 0 {
+		switch {
+		case *limit < 0:
+			fmt.Fprintln(os.Stderr, "error: -limit must not be negative")
+			os.Exit(2)
+		case opts.Input == "nmap" || opts.Input == "har" || opts.Input == "burp" || opts.Input == "http" || opts.Input == "zeek":
+			fmt.Fprintf(os.Stderr, "error: -limit cannot be used with -if %s\n", opts.Input)
+			os.Exit(2)
+		case *watchDir != "":
+			fmt.Fprintln(os.Stderr, "error: -limit cannot be used with -watch-dir")
+			os.Exit(2)
 		}
 	}
-	h, p := stripPort(first)
-	if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
-		h = stripBrackets(h)
+	if *prefixStrip != "" {
+		prefix := *prefixStrip
+		opts.PreProcess = func(line string) (string, bool) {
+			return strings.TrimPrefix(strings.TrimSpace(line), prefix), true
+		}
 	}
-	return h, p, true
-}
-
-func normalizeHost(h string) (string, error) {
-	h = strings.TrimSpace(h)
-	if h == "" {
-		return "", nil
+	if *inFile != "" {
+		f, err := os.Create(*inFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		opts.InScopeOut = f
 	}
-	h = strings.TrimSuffix(h, ".")
-	if ip := net.ParseIP(h); ip != nil {
-		return ip.String(), nil
+	if *outOfScopeFile != "" {
+		f, err := os.Create(*outOfScopeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		opts.OutOfScopeOut = f
 	}
-	if ascii, err := idna.ToASCII(h); err == nil {
-		h = ascii
+	if *excludedOut != "" {
+		f, err := os.Create(*excludedOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		opts.ExcludedOut = f
+	}
+	if *unmatchedOut != "" {
+		f, err := os.Create(*unmatchedOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		opts.UnmatchedOut = f
+	}
+	if *rejectsOut != "" {
+		f, err := os.Create(*rejectsOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		opts.RejectsOut = f
 	}
-	h = strings.ToLower(h)
-	return h, nil
-}
 
-func stripPort(h string) (string, string) {
-	h = strings.TrimSpace(h)
-	if h == "" {
-		return "", ""
-	}
-	if strings.HasPrefix(h, "[") {
-		if idx := strings.LastIndex(h, "]"); idx != -1 {
-			host := h[:idx+1]
-			rest := h[idx+1:]
-			if strings.HasPrefix(rest, ":") {
-				return host, strings.TrimPrefix(rest, ":")
-			}
-			return host, ""
+	var out io.Writer = os.Stdout
+	var outputs []io.Closer
+	if *natsPub != "" {
+		if *outFile != "" {
+			fmt.Fprintln(os.Stderr, "error: -nats-pub cannot be combined with -o")
+			os.Exit(2)
 		}
+		p, err := publishNATS(context.Background(), *natsServer, *natsPub)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error connecting to NATS: %v\n", err)
+			os.Exit(2)
+		}
+		out = p
+		outputs = append(outputs, p)
 	}
-	if host, port, err := net.SplitHostPort(h); err == nil {
-		return host, port
+	if *kafkaPub != "" {
+		if *outFile != "" || *natsPub != "" {
+			fmt.Fprintln(os.Stderr, "error: -kafka-pub cannot be combined with -o or -nats-pub")
+			os.Exit(2)
+		}
+		p := publishKafka(*kafkaServer, *kafkaPub)
+		out = p
+		outputs = append(outputs, p)
+	}
+	if *outFile != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *watchDir != "" {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(*outFile, flags, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		out = f
 	}
-	parts := strings.Split(h, ":")
-	if len(parts) > 1 && net.ParseIP(parts[len(parts)-1]) == nil {
-		p := parts[len(parts)-1]
-		h = strings.Join(parts[:len(parts)-1], ":")
-		return h, p
+
+	if *quiet {
+		out = io.Discard
+	}
+	compression := *compress
+	if compression == "" {
+		compression = nscope.CompressionFor(*outFile)
+	}
+	if compression != "" && !*quiet {
+		cw, err := nscope.Compress(out, compression)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		sw := &syncWriteCloser{w: cw}
+		if *watchDir == "" {
+			go closeOnInterrupt(sw)
+		}
+		out = sw
+		outputs = append(outputs, sw)
+	}
+	if *unique || *sortOutput {
+		if *sortOutput && *watchDir != "" {
+			fmt.Fprintln(os.Stderr, "error: -sort cannot be used with -watch-dir")
+			os.Exit(2)
+		}
+		sep := byte('\n')
+		if *nulSeparated {
+			sep = 0
+		}
+		filter := newLineFilter(out, *unique, *sortOutput, sep)
+		out = filter
+		outputs = append(outputs, filter)
 	}
-	return h, ""
-}
 
-func stripBrackets(s string) string {
-	s = strings.TrimPrefix(s, "[")
-	s = strings.TrimSuffix(s, "]")
-	return s
-}
+	if *newOnly && *stateFile == "" {
+		fmt.Fprintln(os.Stderr, "error: -new-only needs a -state file")
+		os.Exit(2)
+	}
+	if *stateFile != "" {
+		sep := byte('\n')
+		if *nulSeparated {
+			sep = 0
+		}
+		sf, err := openStateFilter(out, *stateFile, *newOnly, sep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		out = sf
+		outputs = append(outputs, sf)
+	}
+	if *sqlitePath != "" {
+		switch {
+		case multi:
+			fmt.Fprintln(os.Stderr, "error: -sqlite works with a single scope")
+			os.Exit(2)
+		case opts.Input == "nmap" || opts.Input == "har" || opts.Input == "burp" || opts.Input == "http" || opts.Input == "zeek":
+			fmt.Fprintf(os.Stderr, "error: -sqlite cannot be used with -if %s\n", opts.Input)
+			os.Exit(2)
+		}
+		sink, err := nscope.OpenSQLite(*sqlitePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Encoder, opts.Rules = sink.Wrap(opts.Encoder), true
+		outputs = append(outputs, sink)
+	}
 
-func matchHost(host, port string, scope []scopeEntry) bool {
-	if host == "" {
-		return false
-	}
-	if ip := net.ParseIP(host); ip != nil {
-		for _, e := range scope {
-			if e.kind != scopeExact {
-				continue
+	if *watchDir != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		state := *watchState
+		if state == "" {
+			state = filepath.Join(*watchDir, ".nscope-processed")
+		}
+		if err := watchDirectory(ctx, *watchDir, state, *watchInterval, scope, opts, out, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "error watching directory: %v\n", err)
+			os.Exit(2)
+		}
+		closeOutputs(outputs)
+		return
+	}
+
+	start := time.Now()
+	var in io.Reader
+	streams := 0
+	for _, s := range []string{*wsURL, *natsSub, *kafkaSub} {
+		if s != "" {
+			streams++
+		}
+	}
+	if streams > 0 {
+		if len(listFiles) > 0 || *follow || *sortOutput || streams > 1 {
+			fmt.Fprintln(os.Stderr, "error: -ws, -nats-sub and -kafka-sub cannot be combined with each other or with -l, -f or -sort")
+			os.Exit(2)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		var rc io.ReadCloser
+		if *natsSub != "" {
+			rc = streamNATS(ctx, *natsServer, *natsSub, os.Stderr)
+		} else if *kafkaSub != "" {
+			rc = streamKafka(ctx, *kafkaServer, *kafkaSub, *kafkaGroup, os.Stderr)
+		} else if rc, err = streamWebSocket(ctx, *wsURL, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		defer rc.Close()
+		in = rc
+	} else if *follow {
+		if len(listFiles) != 1 || strings.Contains(listFiles[0], ",") || *sortOutput {
+			fmt.Fprintln(os.Stderr, "error: -f follows a single -l file and cannot be combined with -sort")
+			os.Exit(2)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fl, err := followFile(ctx, listFiles[0], 250*time.Millisecond)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening list file: %v\n", err)
+			os.Exit(2)
+		}
+		defer fl.Close()
+		in = fl
+	} else if len(listFiles) == 0 {
+		rc, err := nscope.Decompress(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading input: %v\n", err)
+			os.Exit(2)
+		}
+		defer rc.Close()
+		in = rc
+	} else {
+		paths, err := expandPaths(listFiles, opts.Input == "http")
+		if err == nil && len(paths) > 1 {
+			switch opts.Input {
+			case "nmap", "har", "burp", "pcap":
+				err = fmt.Errorf("-if %s reads a single file", opts.Input)
 			}
-			if otherIP := net.ParseIP(e.base); otherIP != nil && otherIP.Equal(ip) {
-				if e.port != "" {
-					if e.port == port {
-						return true
-					}
-					continue
-				}
-				return true
+		}
+		sep := "\n"
+		switch {
+		case opts.Input == "http":
+			sep = "\r\n\r\n"
+		case *nulSeparated:
+			sep = "\x00"
+		}
+		var chain *fileChain
+		if err == nil {
+			chain, err = openFileChain(paths, sep)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening list file: %v\n", err)
+			os.Exit(2)
+		}
+		defer chain.Close()
+		if len(paths) > 1 {
+			sources = chain
+		}
+		in = chain
+	}
+
+	if multi {
+		if opts.Input == "nmap" || opts.Input == "har" || opts.Input == "burp" || opts.Input == "http" || opts.Input == "pcap" || opts.Input == "zeek" {
+			fmt.Fprintf(os.Stderr, "error: -if %s works with a single scope\n", opts.Input)
+			os.Exit(2)
+		}
+		if *outDir != "" {
+			files, err := openScopeOutputs(*outDir, scopes)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error creating output file: %v\n", err)
+				os.Exit(2)
 			}
-			if strings.EqualFold(e.base, host) {
-				if e.port != "" {
-					if e.port == port {
-						return true
-					}
-					continue
-				}
-				return true
+			for _, f := range files {
+				defer f.Close()
 			}
 		}
-		return false
-	}
-	for _, e := range scope {
-		switch e.kind {
-		case scopeExact:
-			if equalHost(host, e.base) {
-				if e.port != "" {
-					if e.port == port {
-						return true
-					}
-					continue
-				}
-				return true
-			}
-		case scopeLeadingWildcard:
-			if matchLeadingWildcard(host, e.base) {
-				if e.port != "" {
-					if e.port == port {
-						return true
-					}
-					continue
-				}
-				return true
+		var st nscope.Stats
+		switch {
+		case *outDir != "":
+			st, err = processScopeFiles(in, scopes, opts, newEncoder)
+		case *outputFormat == "count":
+			st, err = nscope.ProcessLines(in, io.Discard, nil, opts)
+		default:
+			st, err = nscope.ProcessLines(in, out, nil, opts)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error processing lines: %v\n", err)
+			os.Exit(2)
+		}
+		if *outputFormat == "count" && *outDir == "" {
+			for i, sc := range scopes {
+				fmt.Fprintf(out, "%s\t%d\n", sc.name, printedLines(scopeStats(st, i), opts.Reverse))
 			}
-		case scopePatternWildcard:
-			if matchPatternWildcard(host, e.patternLabels) {
-				if e.port != "" {
-					if e.port == port {
-						return true
-					}
-					continue
-				}
-				return true
+		}
+		closeOutputs(outputs)
+		if *stats {
+			printStats(os.Stderr, st, time.Since(start), opts.Resolver)
+			printScopeStats(os.Stderr, scopes, st)
+		}
+		if *unusedRules {
+			for _, sc := range scopes {
+				printUnusedRules(os.Stderr, sc.m)
 			}
 		}
+		if *quiet && printedLines(st, opts.Reverse) == 0 {
+			os.Exit(1)
+		}
+		return
 	}
-	return false
-}
 
-func equalHost(a, b string) bool {
-	a = strings.TrimSuffix(a, ".")
-	b = strings.TrimSuffix(b, ".")
-	return strings.EqualFold(a, b)
-}
-
-func matchLeadingWildcard(host, base string) bool {
-	if equalHost(host, base) {
-		return true
+	process := nscope.ProcessLines
+	switch opts.Input {
+	case "nmap":
+		process = func(r io.Reader, w io.Writer, scope *nscope.Scope, opts nscope.Options) (nscope.Stats, error) {
+			return nscope.ProcessNmap(r, w, scope, opts, *nmapServices)
+		}
+	case "har":
+		process = func(r io.Reader, w io.Writer, scope *nscope.Scope, opts nscope.Options) (nscope.Stats, error) {
+			return nscope.ProcessHAR(r, w, scope, opts, *urlsOnly)
+		}
+	case "burp":
+		process = func(r io.Reader, w io.Writer, scope *nscope.Scope, opts nscope.Options) (nscope.Stats, error) {
+			return nscope.ProcessBurpItems(r, w, scope, opts, *urlsOnly)
+		}
+	case "http":
+		process = func(r io.Reader, w io.Writer, scope *nscope.Scope, opts nscope.Options) (nscope.Stats, error) {
+			return nscope.ProcessHTTPRequests(r, w, scope, opts, *urlsOnly)
+		}
+	case "pcap":
+		process = nscope.ProcessPCAP
+	case "zeek":
+		process = nscope.ProcessZeek
 	}
-	if strings.HasSuffix(host, "."+base) {
-		return true
+	st, err := process(in, out, scope, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error processing lines: %v\n", err)
+		os.Exit(2)
 	}
-	return false
-}
-
-func matchPatternWildcard(host string, pattern []string) bool {
-	host = strings.TrimSuffix(host, ".")
-	hl := strings.Split(host, ".")
-	if len(hl) != len(pattern) {
-		return false
-	}
-	for i := range pattern {
-		p := strings.ToLower(strings.TrimSpace(pattern[i]))
-		if p == "*" {
-			if hl[i] == "" {
-				return false
-			}
-			continue
-		}
-		if !strings.EqualFold(hl[i], p) {
-			return false
+	closeOutputs(outputs)
+	if *stats {
+		printStats(os.Stderr, st, time.Since(start), opts.Resolver)
+	}
+	if *unusedRules {
+		if opts.LiveScope != nil {
+			scope = opts.LiveScope.Load()
 		}
+		printUnusedRules(os.Stderr, scope)
+	}
+	if *quiet && printedLines(st, opts.Reverse) == 0 {
+		os.Exit(1)
 	}
-	return true
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runMerge(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	minimize := fs.Bool("minimize", false, "also drop entries covered by broader entries, such as api.example.com under *.example.com")
+	sorted := fs.Bool("sort", false, "sort the merged entries")
+	// Flags may follow the files, as in nscope merge a.txt b.txt -minimize.
+	var files []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("usage: nscope merge [-minimize] [-sort] SCOPE...")
+	}
+
+	lo := nscope.LoadOptions{KeepASNs: true}
+	var scope *nscope.Scope
+	for _, f := range files {
+		m, err := loadScopeFiles(f, lo)
+		if err != nil {
+			return fmt.Errorf("reading scope file: %w", err)
+		}
+		if scope == nil {
+			scope = m
+		} else {
+			scope.Merge(m)
+		}
+	}
+	var removed int
+	if *minimize {
+		removed = scope.Minimize()
+	} else {
+		removed = scope.Deduplicate()
+	}
+	if removed > 0 {
+		fmt.Fprintf(os.Stderr, "dropped %d redundant entries\n", removed)
+	}
+	return scope.WriteNormalized(w, *sorted)
+}
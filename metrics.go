@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+var latencyBuckets = []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1}
+
+type ruleKey struct {
+	index int
+	text  string
+}
+
+// serverMetrics counts the queries answered by nscope serve and writes them
+// in the Prometheus text format.
+type serverMetrics struct {
+	mu         sync.Mutex
+	lines      uint64
+	verdicts   map[string]uint64
+	unparsable uint64
+	ruleHits   map[ruleKey]uint64
+	buckets    []uint64
+	latencySum float64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		verdicts: make(map[string]uint64),
+		ruleHits: make(map[ruleKey]uint64),
+		buckets:  make([]uint64, len(latencyBuckets)),
+	}
+}
+
+func (m *serverMetrics) observe(res *nscope.Result, ok bool, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines++
+	if !ok {
+		m.unparsable++
+	} else {
+		m.verdicts[res.Verdict.String()]++
+		if res.Rule.Index >= 0 {
+			m.ruleHits[ruleKey{res.Rule.Index, res.Rule.Text}]++
+		}
+	}
+	sec := d.Seconds()
+	m.latencySum += sec
+	for i, b := range latencyBuckets {
+		if sec <= b {
+			m.buckets[i]++
+		}
+	}
+}
+
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintln(w, "# HELP nscope_lines_total Queries answered.")
+	fmt.Fprintln(w, "# TYPE nscope_lines_total counter")
+	fmt.Fprintf(w, "nscope_lines_total %d\n", m.lines)
+	fmt.Fprintln(w, "# HELP nscope_matches_total Queries found in scope.")
+	fmt.Fprintln(w, "# TYPE nscope_matches_total counter")
+	fmt.Fprintf(w, "nscope_matches_total %d\n", m.verdicts["included"])
+	fmt.Fprintln(w, "# HELP nscope_rejects_total Queries found out of scope, by verdict.")
+	fmt.Fprintln(w, "# TYPE nscope_rejects_total counter")
+	for _, v := range []string{"excluded", "unmatched"} {
+		fmt.Fprintf(w, "nscope_rejects_total{verdict=%q} %d\n", v, m.verdicts[v])
+	}
+	fmt.Fprintln(w, "# HELP nscope_unparsable_total Queries without an extractable host.")
+	fmt.Fprintln(w, "# TYPE nscope_unparsable_total counter")
+	fmt.Fprintf(w, "nscope_unparsable_total %d\n", m.unparsable)
+
+	fmt.Fprintln(w, "# HELP nscope_rule_hits_total Queries decided by each scope rule.")
+	fmt.Fprintln(w, "# TYPE nscope_rule_hits_total counter")
+	keys := make([]ruleKey, 0, len(m.ruleHits))
+	for k := range m.ruleHits {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b ruleKey) int {
+		if a.index != b.index {
+			return a.index - b.index
+		}
+		return strings.Compare(a.text, b.text)
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "nscope_rule_hits_total{index=\"%d\",rule=\"%s\"} %d\n", k.index, labelEscaper.Replace(k.text), m.ruleHits[k])
+	}
+
+	fmt.Fprintln(w, "# HELP nscope_match_duration_seconds Time taken to answer a query.")
+	fmt.Fprintln(w, "# TYPE nscope_match_duration_seconds histogram")
+	for i, b := range latencyBuckets {
+		fmt.Fprintf(w, "nscope_match_duration_seconds_bucket{le=\"%g\"} %d\n", b, m.buckets[i])
+	}
+	fmt.Fprintf(w, "nscope_match_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.lines)
+	fmt.Fprintf(w, "nscope_match_duration_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "nscope_match_duration_seconds_count %d\n", m.lines)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
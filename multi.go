@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+type namedScope struct {
+	name string
+	path string
+	m    *nscope.Scope
+	w    io.Writer
+}
+
+var scopeNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func parseScopeArgs(args []string) ([]*namedScope, bool, error) {
+	var scopes []*namedScope
+	named := 0
+	for _, a := range args {
+		if i := strings.IndexByte(a, '='); i > 0 && scopeNameRe.MatchString(a[:i]) {
+			scopes = append(scopes, &namedScope{name: a[:i], path: a[i+1:]})
+			named++
+			continue
+		}
+		scopes = append(scopes, &namedScope{name: a, path: a})
+	}
+	if named > 0 && named != len(scopes) {
+		return nil, false, fmt.Errorf("either name every -s scope (name=path) or none")
+	}
+	if named == 0 && len(scopes) > 1 {
+		spec := strings.Join(args, ",")
+		return []*namedScope{{name: spec, path: spec}}, false, nil
+	}
+	seen := make(map[string]bool)
+	for _, s := range scopes {
+		if seen[s.name] {
+			return nil, false, fmt.Errorf("duplicate scope name %q", s.name)
+		}
+		seen[s.name] = true
+	}
+	return scopes, named > 0, nil
+}
+
+// errScopeFilesLimit stops processScopeFiles once -n lines were written.
+var errScopeFilesLimit = errors.New("limit reached")
+
+// processScopeFiles writes the lines in each named scope (or, with -r, not in
+// it) to that scope's -out-dir file, through an encoder of its own.
+func processScopeFiles(r io.Reader, scopes []*namedScope, opts nscope.Options, newEncoder func() (nscope.OutputEncoder, error)) (nscope.Stats, error) {
+	encs := make([]nscope.OutputEncoder, len(scopes))
+	for i, sc := range scopes {
+		enc, err := newEncoder()
+		if err != nil {
+			return nscope.Stats{}, err
+		}
+		enc.Start(sc.w)
+		encs[i] = enc
+	}
+	printed := 0
+	st, err := nscope.ScanLines(r, nil, opts, func(res *nscope.Result) error {
+		if res.Err != nil && opts.Warnings != nil {
+			fmt.Fprintf(opts.Warnings, "line %d: %v\n", res.LineNo, res.Err)
+		}
+		if res.Skipped || res.Invalid {
+			if opts.RejectsOut != nil {
+				fmt.Fprintf(opts.RejectsOut, "%d\t%s\t%s\n", res.LineNo, res.Reject, res.Line)
+			}
+			return nil
+		}
+		for i, v := range res.ScopeVerdicts {
+			if (v == nscope.Included) == opts.Reverse {
+				continue
+			}
+			sr := *res
+			scopes[i].m.Classify(&sr, opts)
+			sr.Scopes, sr.ScopeVerdicts = nil, nil
+			if err := encs[i].Encode(sr); err != nil {
+				return err
+			}
+		}
+		if (res.Verdict == nscope.Included) != opts.Reverse {
+			printed++
+		}
+		if opts.Limit > 0 && printed >= opts.Limit {
+			return errScopeFilesLimit
+		}
+		return nil
+	})
+	if err == errScopeFilesLimit {
+		err = nil
+	}
+	for _, enc := range encs {
+		if ferr := enc.Finish(); err == nil {
+			err = ferr
+		}
+	}
+	return st, err
+}
+
+func openScopeOutputs(dir string, scopes []*namedScope) ([]*os.File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var files []*os.File
+	for _, sc := range scopes {
+		f, err := os.Create(filepath.Join(dir, sc.name+".txt"))
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+		sc.w = f
+	}
+	return files, nil
+}
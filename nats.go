@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// natsConn is a minimal client for the NATS text protocol: enough to
+// subscribe to one subject and to publish to another.
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+	w    *bufio.Writer
+}
+
+func dialNATS(ctx context.Context, server string) (*natsConn, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("NATS server %q: want a nats:// or tls:// URL", server)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	line, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if infoJSON, ok := strings.CutPrefix(line, "INFO "); !ok || json.Unmarshal([]byte(infoJSON), &info) != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting from NATS server: %q", line)
+	}
+	if u.Scheme == "tls" || info.TLSRequired {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.conn, c.r, c.w = tc, bufio.NewReader(tc), bufio.NewWriter(tc)
+	}
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "nscope", "lang": "go"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts["user"], opts["pass"] = u.User.Username(), pass
+		} else {
+			opts["auth_token"] = u.User.Username()
+		}
+	}
+	connect, _ := json.Marshal(opts)
+	// The PONG confirms the server accepted CONNECT; a rejected login is
+	// answered with -ERR instead.
+	if err := c.send("CONNECT %s\r\nPING\r\n", connect); err != nil {
+		c.Close()
+		return nil, err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		if line == "PONG" {
+			return c, nil
+		}
+		if err := c.control(line); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *natsConn) send(format string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.w, format, args...)
+	return c.w.Flush()
+}
+
+// control handles the protocol lines other than messages.
+func (c *natsConn) control(line string) error {
+	switch {
+	case line == "PING":
+		return c.send("PONG\r\n")
+	case strings.HasPrefix(line, "-ERR"):
+		return fmt.Errorf("NATS server: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+	}
+	return nil
+}
+
+// subscribe writes the payload of every message published to subject to w
+// as a line, until the connection fails.
+func (c *natsConn) subscribe(subject string, w io.Writer) (int, error) {
+	if err := c.send("SUB %s 1\r\n", subject); err != nil {
+		return 0, err
+	}
+	for n := 0; ; {
+		line, err := c.readLine()
+		if err != nil {
+			return n, err
+		}
+		args, ok := strings.CutPrefix(line, "MSG ")
+		if !ok {
+			if err := c.control(line); err != nil {
+				return n, err
+			}
+			continue
+		}
+		f := strings.Fields(args)
+		if len(f) < 3 {
+			return n, fmt.Errorf("malformed NATS message header %q", line)
+		}
+		size, err := strconv.Atoi(f[len(f)-1])
+		if err != nil || size < 0 {
+			return n, fmt.Errorf("malformed NATS message header %q", line)
+		}
+		payload := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return n, err
+		}
+		if err := writeMessage(w, payload[:size]); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func (c *natsConn) Close() error {
+	return c.conn.Close()
+}
+
+// streamNATS reads the messages published to subject as lines, reconnecting
+// when the connection drops.
+func streamNATS(ctx context.Context, server, subject string, warn io.Writer) io.ReadCloser {
+	relay := func(ctx context.Context, w io.Writer) (int, error) {
+		c, err := dialNATS(ctx, server)
+		if err != nil {
+			return 0, err
+		}
+		stop := context.AfterFunc(ctx, func() { c.Close() })
+		defer stop()
+		defer c.Close()
+		return c.subscribe(subject, w)
+	}
+	return reconnectingStream(ctx, "nats", relay, warn)
+}
+
+// natsPublisher publishes every line written to it as a message to subject.
+// It answers the server's pings in the background so an idle publisher is
+// not disconnected.
+type natsPublisher struct {
+	c       *natsConn
+	subject string
+	partial []byte
+	// done receives the error that ended the connection, or nil once the
+	// server answers the PING sent by Close.
+	done chan error
+}
+
+func publishNATS(ctx context.Context, server, subject string) (*natsPublisher, error) {
+	c, err := dialNATS(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	p := &natsPublisher{c: c, subject: subject, done: make(chan error, 1)}
+	go func() {
+		for {
+			line, err := c.readLine()
+			if line == "PONG" {
+				p.done <- nil
+				return
+			}
+			if err == nil {
+				err = c.control(line)
+			}
+			if err != nil {
+				p.done <- err
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+func (p *natsPublisher) Write(b []byte) (int, error) {
+	select {
+	case err := <-p.done:
+		p.done <- err
+		return 0, err
+	default:
+	}
+	n := len(b)
+	p.c.mu.Lock()
+	defer p.c.mu.Unlock()
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i == -1 {
+			p.partial = append(p.partial, b...)
+			break
+		}
+		line := b[:i]
+		if len(p.partial) > 0 {
+			line = append(p.partial, line...)
+			p.partial = p.partial[:0]
+		}
+		p.publish(line)
+		b = b[i+1:]
+	}
+	if err := p.c.w.Flush(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (p *natsPublisher) publish(msg []byte) {
+	fmt.Fprintf(p.c.w, "PUB %s %d\r\n", p.subject, len(msg))
+	p.c.w.Write(msg)
+	p.c.w.WriteString("\r\n")
+}
+
+// Close publishes an unterminated last line and waits for the server to
+// acknowledge everything sent before closing the connection.
+func (p *natsPublisher) Close() error {
+	p.c.mu.Lock()
+	if len(p.partial) > 0 {
+		p.publish(p.partial)
+		p.partial = nil
+	}
+	p.c.mu.Unlock()
+	if err := p.c.send("PING\r\n"); err != nil {
+		p.c.Close()
+		return err
+	}
+	err := <-p.done
+	p.c.Close()
+	return err
+}
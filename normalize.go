@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func runNormalize(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	sorted := fs.Bool("sort", false, "sort entries and drop exact repeats")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return fmt.Errorf("-s scope file is required")
+	}
+	scope, err := loadScopeFiles(*scopeFile, nscope.LoadOptions{KeepASNs: true})
+	if err != nil {
+		return fmt.Errorf("reading scope file: %w", err)
+	}
+	return scope.WriteNormalized(w, *sorted)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+const sortChunkBytes = 64 << 20
+
+// lineFilter post-processes output line by line. With unique set it drops
+// lines already written, remembering only a 64-bit hash of each. With sorted
+// set it buffers lines until Close, spilling sorted runs to temporary files
+// once sortChunkBytes is exceeded and merging them at the end.
+type lineFilter struct {
+	w      io.Writer
+	unique bool
+	sorted bool
+	seed   maphash.Seed
+	seen   map[uint64]struct{}
+	split  lineSplitter
+	lines  [][]byte
+	size   int
+	runs   []string
+	sep    byte
+}
+
+func newLineFilter(w io.Writer, unique, sorted bool, sep byte) *lineFilter {
+	return &lineFilter{w: w, unique: unique, sorted: sorted, sep: sep, split: lineSplitter{sep: sep}, seed: maphash.MakeSeed(), seen: make(map[uint64]struct{})}
+}
+
+func (f *lineFilter) Write(p []byte) (int, error) {
+	if err := f.split.write(p, f.line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// lineSplitter cuts written data into sep-terminated lines, holding on to an
+// unterminated tail until the next write.
+type lineSplitter struct {
+	sep     byte
+	partial []byte
+}
+
+func (s *lineSplitter) write(p []byte, fn func([]byte) error) error {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, s.sep)
+		if i == -1 {
+			s.partial = append(s.partial, p...)
+			break
+		}
+		line := p[:i]
+		if len(s.partial) > 0 {
+			line = append(s.partial, line...)
+			s.partial = s.partial[:0]
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+		p = p[i+1:]
+	}
+	return nil
+}
+
+// flush passes an unterminated last line to fn.
+func (s *lineSplitter) flush(fn func([]byte) error) error {
+	if len(s.partial) == 0 {
+		return nil
+	}
+	line := s.partial
+	s.partial = nil
+	return fn(line)
+}
+
+func (f *lineFilter) line(line []byte) error {
+	if f.sorted {
+		f.lines = append(f.lines, bytes.Clone(line))
+		f.size += len(line) + 24
+		if f.size >= sortChunkBytes {
+			return f.spill()
+		}
+		return nil
+	}
+	if f.unique {
+		h := maphash.Bytes(f.seed, line)
+		if _, ok := f.seen[h]; ok {
+			return nil
+		}
+		f.seen[h] = struct{}{}
+	}
+	return f.writeLine(f.w, line)
+}
+
+func (f *lineFilter) spill() error {
+	slices.SortFunc(f.lines, bytes.Compare)
+	tmp, err := os.CreateTemp("", "nscope-sort-*")
+	if err != nil {
+		return err
+	}
+	f.runs = append(f.runs, tmp.Name())
+	bw := bufio.NewWriter(tmp)
+	for _, l := range f.lines {
+		if err := f.writeLine(bw, l); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	f.lines, f.size = f.lines[:0], 0
+	if err := bw.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+// Close writes out any unterminated last line and, when sorting, the sorted
+// lines.
+func (f *lineFilter) Close() error {
+	if err := f.split.flush(f.line); err != nil {
+		return err
+	}
+	if !f.sorted {
+		return nil
+	}
+	defer func() {
+		for _, name := range f.runs {
+			os.Remove(name)
+		}
+	}()
+	if len(f.runs) == 0 {
+		slices.SortFunc(f.lines, bytes.Compare)
+		var last []byte
+		for i, l := range f.lines {
+			if f.unique && i > 0 && bytes.Equal(l, last) {
+				continue
+			}
+			if err := f.writeLine(f.w, l); err != nil {
+				return err
+			}
+			last = l
+		}
+		return nil
+	}
+	if len(f.lines) > 0 {
+		if err := f.spill(); err != nil {
+			return err
+		}
+	}
+	return f.merge()
+}
+
+// closeOutputs closes the output wrappers innermost last, so buffered lines
+// reach the compressor before it is flushed.
+func closeOutputs(outputs []io.Closer) {
+	for i := len(outputs) - 1; i >= 0; i-- {
+		if err := outputs[i].Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+			os.Exit(2)
+		}
+	}
+}
+
+// syncWriteCloser serializes writes with Close so an interrupt can flush the
+// compressed output while lines are still being written.
+type syncWriteCloser struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	closed bool
+}
+
+func (s *syncWriteCloser) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+	return s.w.Write(p)
+}
+
+func (s *syncWriteCloser) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.w.Close()
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func closeOnInterrupt(w io.Closer) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+	}
+	os.Exit(130)
+}
+
+func (f *lineFilter) merge() error {
+	h := &runHeap{}
+	for _, name := range f.runs {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		s := nscope.NewLineScanner(file)
+		if f.sep == 0 {
+			s.Split(nscope.ScanNulRecords)
+		}
+		if s.Scan() {
+			heap.Push(h, s)
+		} else if err := s.Err(); err != nil {
+			return err
+		}
+	}
+	var last []byte
+	first := true
+	for h.Len() > 0 {
+		s := (*h)[0]
+		line := s.Bytes()
+		if !f.unique || first || !bytes.Equal(line, last) {
+			if err := f.writeLine(f.w, line); err != nil {
+				return err
+			}
+			last = append(last[:0], line...)
+			first = false
+		}
+		if s.Scan() {
+			heap.Fix(h, 0)
+		} else {
+			if err := s.Err(); err != nil {
+				return err
+			}
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+type runHeap []*bufio.Scanner
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return bytes.Compare(h[i].Bytes(), h[j].Bytes()) < 0 }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*bufio.Scanner)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+func (f *lineFilter) writeLine(w io.Writer, line []byte) error {
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{f.sep})
+	return err
+}
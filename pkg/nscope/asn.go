@@ -0,0 +1,127 @@
+package nscope
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ripeStatAPI = "https://stat.ripe.net/data/announced-prefixes/data.json"
+
+type ASNProvider func(asn uint32) ([]netip.Prefix, error)
+
+func RIPEStatASN(asn uint32) ([]netip.Prefix, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ripeStatAPI+"?resource=AS"+strconv.FormatUint(uint64(asn), 10), nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Data struct {
+			Prefixes []struct {
+				Prefix string `json:"prefix"`
+			} `json:"prefixes"`
+		} `json:"data"`
+	}
+	if err := getJSON(req, &doc); err != nil {
+		return nil, fmt.Errorf("fetching prefixes for AS%d: %w", asn, err)
+	}
+	var out []netip.Prefix
+	for _, p := range doc.Data.Prefixes {
+		if pfx, err := netip.ParsePrefix(p.Prefix); err == nil {
+			out = append(out, pfx.Masked())
+		}
+	}
+	return out, nil
+}
+
+func LoadASNFile(path string) (ASNProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	table := make(map[uint32][]netip.Prefix)
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		fields := strings.FieldsFunc(sc.Text(), func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var prefixes []netip.Prefix
+		asnField := fields[1]
+		if p, err := netip.ParsePrefix(fields[0]); err == nil {
+			prefixes = []netip.Prefix{p.Masked()}
+		} else if len(fields) >= 3 {
+			r, ok := parseRangeEntry(fields[0] + "-" + fields[1])
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: invalid prefix or range", path, lineNo)
+			}
+			prefixes, asnField = r.info().prefixes, fields[2]
+		} else {
+			return nil, fmt.Errorf("%s:%d: invalid prefix %q", path, lineNo, fields[0])
+		}
+		asn, err := parseASN(asnField)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		table[asn] = append(table[asn], prefixes...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return func(asn uint32) ([]netip.Prefix, error) {
+		return table[asn], nil
+	}, nil
+}
+
+func parseASN(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return uint32(n), nil
+}
+
+func (m *Scope) expandASNs(lo LoadOptions) error {
+	if lo.KeepASNs {
+		return nil
+	}
+	provider := lo.ASN
+	if provider == nil {
+		provider = RIPEStatASN
+	}
+	cache := make(map[uint32][]netip.Prefix)
+	for i := range m.entries {
+		e := &m.entries[i]
+		if e.kind != scopeASN || e.info().prefixes != nil {
+			continue
+		}
+		asn, err := parseASN(e.base)
+		if err != nil {
+			return err
+		}
+		prefixes, ok := cache[asn]
+		if !ok {
+			if prefixes, err = provider(asn); err != nil {
+				return fmt.Errorf("%s: %w", e.location(), err)
+			}
+			cache[asn] = prefixes
+		}
+		if len(prefixes) == 0 {
+			return fmt.Errorf("%s: no prefixes found for AS%d", e.location(), asn)
+		}
+		e.setInfo().prefixes = prefixes
+	}
+	m.buildIndexes()
+	return nil
+}
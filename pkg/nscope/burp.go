@@ -0,0 +1,309 @@
+package nscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxPatternPorts = 4096
+
+type burpDocument struct {
+	Target struct {
+		Scope *burpScope `json:"scope"`
+	} `json:"target"`
+}
+
+type burpScope struct {
+	AdvancedMode bool       `json:"advanced_mode"`
+	Include      []burpRule `json:"include"`
+	Exclude      []burpRule `json:"exclude"`
+}
+
+type burpRule struct {
+	Enabled  bool   `json:"enabled"`
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	File     string `json:"file"`
+	Prefix   string `json:"prefix,omitempty"`
+}
+
+var (
+	burpLiteralHostRe = regexp.MustCompile(`^(?:[A-Za-z0-9-]|\\\.)+$`)
+	burpPortsRe       = regexp.MustCompile(`^[0-9]+(?:\|[0-9]+)*$`)
+	burpPathRe        = regexp.MustCompile(`^(?:[A-Za-z0-9_~%!,;=@:-]|\\[./-]|/)*$`)
+)
+
+func isBurpDocument(b []byte) bool {
+	var doc burpDocument
+	return json.Unmarshal(b, &doc) == nil && doc.Target.Scope != nil
+}
+
+func ParseBurpScope(b []byte, file string) (*Scope, error) {
+	var doc burpDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Target.Scope == nil {
+		return nil, fmt.Errorf("no target scope in Burp document")
+	}
+	var out []scopeEntry
+	n := 0
+	for _, list := range []struct {
+		rules   []burpRule
+		exclude bool
+	}{{doc.Target.Scope.Include, false}, {doc.Target.Scope.Exclude, true}} {
+		for _, r := range list.rules {
+			n++
+			if !r.Enabled {
+				continue
+			}
+			e, err := r.entry()
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %d: %w", file, n, err)
+			}
+			e.exclude = list.exclude
+			e.raw = e.canonical()
+			e.altered = false
+			e.file = file
+			e.line = n
+			out = append(out, e)
+		}
+	}
+	return newScope(out), nil
+}
+
+func (r burpRule) entry() (scopeEntry, error) {
+	if r.Prefix != "" {
+		return burpPrefixEntry(r.Prefix)
+	}
+	e, err := parseScopeLine(burpHost(r.Host))
+	if err != nil {
+		return scopeEntry{}, err
+	}
+	if p := strings.ToLower(r.Protocol); p != "" && p != "any" {
+		e.scheme = p
+	}
+	if e.port, err = burpPorts(r.Port); err != nil {
+		return scopeEntry{}, err
+	}
+	if e.port != "" {
+		if e.ports, err = parsePortSpec(e.port); err != nil {
+			return scopeEntry{}, err
+		}
+	}
+	if e.path, err = burpPath(r.File); err != nil {
+		return scopeEntry{}, err
+	}
+	return e, nil
+}
+
+func burpPrefixEntry(prefix string) (scopeEntry, error) {
+	u, err := url.Parse(prefix)
+	if err != nil || u.Host == "" {
+		return scopeEntry{}, fmt.Errorf("invalid URL prefix %q", prefix)
+	}
+	line := u.Scheme + "://" + u.Host
+	if u.Path != "" && u.Path != "/" {
+		line += u.Path + "*"
+	}
+	return parseScopeLine(line)
+}
+
+func burpHost(expr string) string {
+	s := strings.TrimSuffix(strings.TrimPrefix(expr, "^"), "$")
+	for _, opt := range []string{`(.*\.)?`, `(?:.*\.)?`} {
+		if rest, ok := strings.CutPrefix(s, opt); ok && burpLiteralHostRe.MatchString(rest) {
+			return "*." + strings.ReplaceAll(rest, `\.`, ".")
+		}
+	}
+	if s == "" || s == ".*" {
+		return "re:."
+	}
+	if !strings.HasPrefix(expr, "^") || !strings.HasSuffix(expr, "$") {
+		return "re:" + expr
+	}
+	labels := strings.Split(s, `\.`)
+	for i, l := range labels {
+		switch {
+		case l == `[^.]+`:
+			labels[i] = "*"
+		case !burpLiteralHostRe.MatchString(l):
+			return "re:" + expr
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func burpPorts(expr string) (string, error) {
+	s := strings.TrimSuffix(strings.TrimPrefix(expr, "^"), "$")
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(s, "("), "?:"), ")")
+	if s == "" || s == ".*" {
+		return "", nil
+	}
+	if !burpPortsRe.MatchString(s) {
+		return "", fmt.Errorf("unsupported port pattern %q", expr)
+	}
+	return strings.ReplaceAll(s, "|", ","), nil
+}
+
+func burpPath(expr string) (string, error) {
+	s := strings.TrimPrefix(expr, "^")
+	if s == "" || s == ".*" || s == "/.*" {
+		return "", nil
+	}
+	lit, prefix := strings.CutSuffix(s, ".*")
+	if !prefix {
+		var ok bool
+		if lit, ok = strings.CutSuffix(s, "$"); !ok {
+			prefix = true
+		}
+	}
+	if !strings.HasPrefix(expr, "^") || !strings.HasPrefix(lit, "/") || !burpPathRe.MatchString(lit) {
+		return "", fmt.Errorf("unsupported file pattern %q", expr)
+	}
+	lit = strings.NewReplacer(`\.`, ".", `\/`, "/", `\-`, "-").Replace(lit)
+	if prefix {
+		lit += "*"
+	}
+	return lit, nil
+}
+
+func (m *Scope) burpDocument() (burpDocument, error) {
+	sc := &burpScope{AdvancedMode: true, Include: []burpRule{}, Exclude: []burpRule{}}
+	for _, e := range m.entries {
+		rules, err := e.burpRules()
+		if err != nil {
+			return burpDocument{}, fmt.Errorf("%s: %s: %w", e.location(), e.label(), err)
+		}
+		if e.exclude {
+			sc.Exclude = append(sc.Exclude, rules...)
+		} else {
+			sc.Include = append(sc.Include, rules...)
+		}
+	}
+	var doc burpDocument
+	doc.Target.Scope = sc
+	return doc, nil
+}
+
+func (m *Scope) MarshalBurp() ([]byte, error) {
+	doc, err := m.burpDocument()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (e scopeEntry) burpRules() ([]burpRule, error) {
+	var hosts []string
+	switch e.kind {
+	case scopeExact:
+		hosts = []string{"^" + regexp.QuoteMeta(e.base) + "$"}
+	case scopeLeadingWildcard:
+		hosts = []string{`^(.*\.)?` + regexp.QuoteMeta(e.base) + "$"}
+	case scopePatternWildcard:
+		labels := make([]string, len(e.patternLabels))
+		for i, l := range e.patternLabels {
+			labels[i] = labelRegexp(l, `[^.]`)
+		}
+		hosts = []string{"^" + strings.Join(labels, `\.`) + "$"}
+	case scopeTLDWildcard:
+		return nil, fmt.Errorf("public suffix wildcards cannot be expressed as a host pattern")
+	case scopeAny:
+		hosts = []string{"^.+$"}
+	case scopeRegex:
+		hosts = []string{e.base}
+	case scopeCIDR, scopeRange, scopeASN:
+		prefixes := e.info().prefixes
+		if e.kind == scopeCIDR {
+			prefixes = []netip.Prefix{e.network}
+		}
+		for _, p := range prefixes {
+			h, err := prefixPattern(p)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, "^"+h+"$")
+		}
+	}
+	port, err := e.burpPort()
+	if err != nil {
+		return nil, err
+	}
+	protocol := e.scheme
+	if protocol == "" {
+		protocol = "any"
+	}
+	var file string
+	if e.path != "" {
+		if prefix, ok := strings.CutSuffix(e.path, "*"); ok {
+			file = "^" + regexp.QuoteMeta(prefix) + ".*"
+		} else {
+			file = "^" + regexp.QuoteMeta(e.path) + "$"
+		}
+	}
+	rules := make([]burpRule, 0, len(hosts))
+	for _, h := range hosts {
+		rules = append(rules, burpRule{Enabled: true, Protocol: protocol, Host: h, Port: port, File: file})
+	}
+	return rules, nil
+}
+
+func (e scopeEntry) burpPort() (string, error) {
+	ports, err := e.portList()
+	if err != nil || len(ports) == 0 {
+		return "", err
+	}
+	if len(ports) == 1 {
+		return "^" + ports[0] + "$", nil
+	}
+	return "^(" + strings.Join(ports, "|") + ")$", nil
+}
+
+func (e scopeEntry) portList() ([]string, error) {
+	var ports []string
+	for _, r := range e.ports {
+		if len(ports)+r.hi-r.lo+1 > maxPatternPorts {
+			return nil, fmt.Errorf("port ranges cover more than %d ports", maxPatternPorts)
+		}
+		for p := r.lo; p <= r.hi; p++ {
+			ports = append(ports, strconv.Itoa(p))
+		}
+	}
+	return ports, nil
+}
+
+func prefixPattern(p netip.Prefix) (string, error) {
+	if p.IsSingleIP() {
+		return regexp.QuoteMeta(p.Addr().String()), nil
+	}
+	if !p.Addr().Is4() {
+		return "", fmt.Errorf("IPv6 network %s cannot be expressed as a host pattern", p)
+	}
+	a := p.Addr().As4()
+	full, rem := p.Bits()/8, p.Bits()%8
+	octets := make([]string, 4)
+	for i := range octets {
+		switch {
+		case i < full:
+			octets[i] = strconv.Itoa(int(a[i]))
+		case i == full && rem != 0:
+			lo := int(a[i])
+			hi := lo | (1<<(8-rem) - 1)
+			vals := make([]string, 0, hi-lo+1)
+			for v := lo; v <= hi; v++ {
+				vals = append(vals, strconv.Itoa(v))
+			}
+			octets[i] = "(" + strings.Join(vals, "|") + ")"
+		default:
+			octets[i] = "[0-9]{1,3}"
+		}
+	}
+	return strings.Join(octets, `\.`), nil
+}
@@ -0,0 +1,61 @@
+package nscope
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+type burpItem struct {
+	URL      string `xml:"url"`
+	Host     string `xml:"host"`
+	Port     string `xml:"port"`
+	Protocol string `xml:"protocol"`
+}
+
+func (it burpItem) target() string {
+	if it.URL != "" {
+		return it.URL
+	}
+	if it.Protocol == "" || it.Port == "" {
+		return it.Host
+	}
+	return it.Protocol + "://" + net.JoinHostPort(it.Host, it.Port)
+}
+
+// ProcessBurpItems filters a Burp "Save items" XML export, keeping the
+// items whose URL is in scope.
+func ProcessBurpItems(r io.Reader, w io.Writer, scope *Scope, opts Options, urlsOnly bool) (Stats, error) {
+	var st Stats
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return st, err
+	}
+	out := w
+	if urlsOnly {
+		out = io.Discard
+	}
+	err = filterXML(doc, out, "item", func(dec *xml.Decoder, se *xml.StartElement) (bool, error) {
+		var it burpItem
+		if err := dec.DecodeElement(&it, se); err != nil {
+			return false, err
+		}
+		it.URL = strings.TrimSpace(it.URL)
+		u := it.target()
+		if v, ok := scope.classifyURL(u, opts, &st); !ok || (v == Included) == opts.Reverse {
+			return false, nil
+		}
+		if urlsOnly {
+			if _, err := fmt.Fprintln(w, u); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return st, fmt.Errorf("reading Burp items: %w", err)
+	}
+	return st, nil
+}
@@ -0,0 +1,46 @@
+package nscope
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBurpItemsInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+	process := func(urlsOnly bool) func(io.Reader, io.Writer, *Scope, Options) (Stats, error) {
+		return func(r io.Reader, w io.Writer, m *Scope, opts Options) (Stats, error) {
+			return ProcessBurpItems(r, w, m, opts, urlsOnly)
+		}
+	}
+	rejects := map[string]int{RejectBadURL: 1}
+
+	got, st := processFixture(t, "burp-items.xml", scope, Options{Input: "burp"}, process(true))
+	checkFixture(t, got, st, []string{"https://app.example.com/login", "http://10.1.2.3:8080"}, rejects)
+	got, st = processFixture(t, "burp-items.xml", scope, Options{Input: "burp", Reverse: true}, process(true))
+	checkFixture(t, got, st, []string{"https://other.org/"}, rejects)
+
+	got, _ = processFixture(t, "burp-items.xml", scope, Options{Input: "burp"}, process(false))
+	doc := strings.Join(got, "\n")
+	if n := strings.Count(doc, "<item>"); n != 2 {
+		t.Errorf("filtered export has %d items, want 2", n)
+	}
+	for _, s := range []string{"<!DOCTYPE items [", `burpVersion="2024.1"`, "R0VUIC9sb2dpbiBIVFRQLzEuMQ0KDQo=", ">10.1.2.3</host>"} {
+		if !strings.Contains(doc, s) {
+			t.Errorf("filtered export lacks %s", s)
+		}
+	}
+	for _, s := range []string{"other.org", "::1"} {
+		if strings.Contains(doc, s) {
+			t.Errorf("filtered export keeps %s", s)
+		}
+	}
+
+	m, err := ParseScope(strings.NewReader(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessBurpItems(strings.NewReader("<items><item><url>x</item></items>"), io.Discard, m, Options{}, false); err == nil {
+		t.Error("malformed Burp items were accepted")
+	}
+}
@@ -0,0 +1,61 @@
+package nscope
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type certstreamMessage struct {
+	MessageType string          `json:"message_type"`
+	Data        json.RawMessage `json:"data"`
+}
+
+type certstreamUpdate struct {
+	LeafCert struct {
+		AllDomains []string `json:"all_domains"`
+	} `json:"leaf_cert"`
+}
+
+// prepareCertstreamLine takes the domains of a certstream message, either a
+// certificate_update from the full feed or a dns_entries message from the
+// domains-only feed. Heartbeats and other messages are skipped. A wildcard
+// name is matched as its parent domain.
+func prepareCertstreamLine(res *Result, input string, opts Options, st *Stats) bool {
+	var msg certstreamMessage
+	if err := json.Unmarshal([]byte(input), &msg); err != nil {
+		return rejectLine(res, st, RejectRecord)
+	}
+	var domains []string
+	switch msg.MessageType {
+	case "certificate_update":
+		var u certstreamUpdate
+		if err := json.Unmarshal(msg.Data, &u); err != nil {
+			return rejectLine(res, st, RejectRecord)
+		}
+		domains = u.LeafCert.AllDomains
+	case "dns_entries":
+		if err := json.Unmarshal(msg.Data, &domains); err != nil {
+			return rejectLine(res, st, RejectRecord)
+		}
+	default:
+		return skipLine(res, st, RejectIgnored)
+	}
+	res.candidates = res.candidates[:0]
+	for _, d := range domains {
+		d = strings.TrimPrefix(d, "*.")
+		if !withinHostLimits(d, opts.MaxHostLength) {
+			continue
+		}
+		h, err := normalizeHost(d)
+		if err != nil || h == "" {
+			continue
+		}
+		res.candidates = append(res.candidates, extracted{host: h})
+	}
+	if len(res.candidates) == 0 {
+		return rejectLine(res, st, RejectNoHost)
+	}
+	st.Parsed++
+	res.Host = res.candidates[0].host
+	return true
+}
@@ -0,0 +1,32 @@
+package nscope
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+)
+
+const compiledMagic = "NSCOPE\x00\x01"
+
+// MarshalBinary encodes the normalized entries of m. The matching indexes
+// are not stored; UnmarshalBinary builds them again.
+func (m *Scope) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(compiledMagic)
+	if err := gob.NewEncoder(&buf).Encode(m.document()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Scope) UnmarshalBinary(b []byte) error {
+	rest, ok := bytes.CutPrefix(b, []byte(compiledMagic))
+	if !ok {
+		return errors.New("not a compiled nscope scope")
+	}
+	var doc scopeDocument
+	if err := gob.NewDecoder(bytes.NewReader(rest)).Decode(&doc); err != nil {
+		return err
+	}
+	return m.setDocument(doc)
+}
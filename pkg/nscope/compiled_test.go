@@ -0,0 +1,61 @@
+package nscope
+
+import (
+	"bytes"
+	"testing"
+)
+
+// BenchmarkLoadCompiledScope1M compares loading a scope of one million
+// entries as text (-s) and compiled (-S). Both build the matching indexes,
+// which the index case measures alone.
+func BenchmarkLoadCompiledScope1M(b *testing.B) {
+	data := syntheticScope(1_000_000)
+	m, err := ReadScope(bytes.NewReader(data), "scope.txt", LoadOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	compiled, err := m.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("text", func(b *testing.B) {
+		for range b.N {
+			if _, err := ReadScope(bytes.NewReader(data), "scope.txt", LoadOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("compiled", func(b *testing.B) {
+		for range b.N {
+			if _, err := ReadScope(bytes.NewReader(compiled), "scope.bin", LoadOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			m.buildIndexes()
+		}
+	})
+}
+
+func TestCompiledRoundTrip(t *testing.T) {
+	m, err := ParseScope(bytes.NewReader(syntheticScope(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadScope(bytes.NewReader(b), "scope.bin", LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"host2.example2.com", "x.svc0.example0.com", "api.a.env1.example1.com", "other.example3.com"} {
+		want, _ := m.Match(host, "")
+		if ok, _ := got.Match(host, ""); ok != want {
+			t.Errorf("%s: compiled scope matches %v, want %v", host, ok, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress returns a reader over the decompressed contents of r when it
+// starts with a gzip, bzip2 or zstd header, and over r itself otherwise.
+// Go has no zstd decoder in the standard library, so zstd data is piped
+// through the zstd command.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		cmd := exec.Command("zstd", "-dcq")
+		cmd.Stdin = br
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("reading zstd input: %w", err)
+		}
+		return &commandReader{ReadCloser: out, cmd: cmd}, nil
+	}
+	return io.NopCloser(br), nil
+}
+
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if err == io.EOF {
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("zstd: %w", werr)
+		}
+		c.cmd = nil
+	}
+	return n, err
+}
+
+func (c *commandReader) Close() error {
+	err := c.ReadCloser.Close()
+	if c.cmd != nil {
+		c.cmd.Wait()
+	}
+	return err
+}
+
+// Compress wraps w so that everything written is compressed with format,
+// "gzip" or "zstd". Close flushes the compressed stream; it does not close w.
+// The zstd command runs in its own process group so an interrupt reaching
+// nscope does not cut its output short before Close.
+func Compress(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		cmd := exec.Command("zstd", "-cq")
+		cmd.Stdout = w
+		detach(cmd)
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("writing zstd output: %w", err)
+		}
+		return &commandWriter{WriteCloser: in, cmd: cmd}, nil
+	}
+	return nil, fmt.Errorf("unknown compression %q (available: gzip, zstd)", format)
+}
+
+// CompressionFor returns the compression implied by the extension of path.
+func CompressionFor(path string) string {
+	switch filepath.Ext(path) {
+	case ".gz":
+		return "gzip"
+	case ".zst":
+		return "zstd"
+	}
+	return ""
+}
+
+type commandWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandWriter) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return c.cmd.Wait()
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package nscope
+
+import "os/exec"
+
+func detach(*exec.Cmd) {}
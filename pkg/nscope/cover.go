@@ -0,0 +1,251 @@
+package nscope
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// covers reports whether e matches every target that o matches, ignoring
+// whether either is an exclusion. It errs on the side of false for kinds it
+// cannot compare, such as two regexes.
+func (e *scopeEntry) covers(o *scopeEntry) bool {
+	if e.scheme != "" && e.scheme != o.scheme {
+		return false
+	}
+	if e.path != "" && e.path != o.path {
+		prefix, ok := strings.CutSuffix(e.path, "*")
+		if !ok || !strings.HasPrefix(o.path, prefix) {
+			return false
+		}
+	}
+	if !e.coversPorts(o) {
+		return false
+	}
+	switch e.kind {
+	case scopeAny:
+		return true
+	case scopeExact:
+		return o.kind == scopeExact && o.base == e.base
+	case scopeLeadingWildcard:
+		switch o.kind {
+		case scopeExact:
+			return (o.base == e.base && !e.noApex) || strings.HasSuffix(o.base, "."+e.base)
+		case scopeLeadingWildcard:
+			return (o.base == e.base && (!e.noApex || o.noApex)) || strings.HasSuffix(o.base, "."+e.base)
+		case scopePatternWildcard:
+			suffix := strings.Split(e.base, ".")
+			if len(o.patternLabels) <= len(suffix) {
+				return false
+			}
+			tail := o.patternLabels[len(o.patternLabels)-len(suffix):]
+			for i, l := range suffix {
+				if tail[i] != l {
+					return false
+				}
+			}
+			return true
+		}
+	case scopePatternWildcard:
+		switch o.kind {
+		case scopeExact:
+			return !isIPHost(o.base) && matchPatternWildcard(o.base, e.patternLabels)
+		case scopePatternWildcard:
+			if len(o.patternLabels) != len(e.patternLabels) {
+				return false
+			}
+			for i, l := range e.patternLabels {
+				ol := o.patternLabels[i]
+				switch {
+				case l == "*" || l == ol:
+				case strings.Contains(l, "*") && !strings.Contains(ol, "*") && matchLabelGlob(l, ol):
+				default:
+					return false
+				}
+			}
+			return true
+		}
+	case scopeRegex:
+		return o.kind == scopeExact && !isIPHost(o.base) && e.info().re.MatchString(o.base)
+	case scopeTLDWildcard:
+		if o.kind == scopeTLDWildcard {
+			return o.base == e.base && (!e.noApex || o.noApex) || strings.HasPrefix(e.base, "*.") && strings.HasSuffix(o.base, "."+strings.TrimPrefix(e.base, "*."))
+		}
+		return o.kind == scopeExact && !isIPHost(o.base) && e.matchTLDWildcard(o.base)
+	case scopeCIDR, scopeRange, scopeASN:
+		nets := e.info().prefixes
+		if e.kind == scopeCIDR {
+			nets = []netip.Prefix{e.network}
+		}
+		switch o.kind {
+		case scopeExact:
+			addr, err := netip.ParseAddr(o.base)
+			if err != nil {
+				return false
+			}
+			return prefixesCover(nets, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		case scopeCIDR:
+			return prefixesCover(nets, o.network)
+		case scopeRange, scopeASN:
+			if len(o.info().prefixes) == 0 {
+				return false
+			}
+			for _, p := range o.info().prefixes {
+				if !prefixesCover(nets, p) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// coversPorts reports whether e accepts every port o accepts.
+func (e *scopeEntry) coversPorts(o *scopeEntry) bool {
+	if e.port == "" {
+		return true
+	}
+	if o.port == "" {
+		return false
+	}
+	if e.port == o.port {
+		return true
+	}
+	for _, r := range o.ports {
+		for p := r.lo; p <= r.hi; p++ {
+			if !e.portMatches(strconv.Itoa(p)) {
+				return false
+			}
+		}
+	}
+	return len(o.ports) > 0
+}
+
+func prefixesCover(nets []netip.Prefix, p netip.Prefix) bool {
+	for _, n := range nets {
+		if n.Bits() <= p.Bits() && n.Contains(p.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPHost(h string) bool {
+	_, err := netip.ParseAddr(h)
+	return err == nil
+}
+
+// coverIndex finds the entries of one polarity that may cover another entry
+// without comparing it to every entry: names are looked up by their parent
+// domains, and only address, pattern and regex entries are scanned.
+type coverIndex struct {
+	entries []scopeEntry
+	names   map[string][]int
+	others  []int
+}
+
+func newCoverIndex(entries []scopeEntry, exclude bool) *coverIndex {
+	idx := &coverIndex{entries: entries, names: make(map[string][]int)}
+	now := time.Now()
+	for i := range entries {
+		e := &entries[i]
+		if e.exclude != exclude || e.expiredAt(now) {
+			continue
+		}
+		switch e.kind {
+		case scopeExact, scopeLeadingWildcard:
+			idx.names[e.base] = append(idx.names[e.base], i)
+		default:
+			idx.others = append(idx.others, i)
+		}
+	}
+	return idx
+}
+
+// covering returns the first entry other than entries[j] that covers it, or
+// -1. Of two entries of the same polarity covering each other only the later
+// one is reported as covered.
+func (idx *coverIndex) covering(j int) int {
+	o := &idx.entries[j]
+	check := func(i int) bool {
+		if i == j {
+			return false
+		}
+		e := &idx.entries[i]
+		return e.covers(o) && (i < j || e.exclude != o.exclude || !o.covers(e))
+	}
+	if o.kind == scopeExact || o.kind == scopeLeadingWildcard {
+		for s := o.base; ; {
+			for _, i := range idx.names[s] {
+				if check(i) {
+					return i
+				}
+			}
+			dot := strings.IndexByte(s, '.')
+			if dot == -1 {
+				break
+			}
+			s = s[dot+1:]
+		}
+	}
+	if o.kind == scopePatternWildcard && len(o.patternLabels) > 0 {
+		for k := range o.patternLabels {
+			for _, i := range idx.names[strings.Join(o.patternLabels[k:], ".")] {
+				if check(i) {
+					return i
+				}
+			}
+		}
+	}
+	for _, i := range idx.others {
+		if check(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Deduplicate drops entries that repeat an earlier one and returns how many
+// were dropped.
+func (m *Scope) Deduplicate() int {
+	seen := make(map[string]bool)
+	kept := m.entries[:0:0]
+	for _, e := range m.entries {
+		key := e.canonical()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, e)
+	}
+	return m.keep(kept)
+}
+
+// Minimize drops entries that cannot change a verdict: repeats, entries
+// covered by a broader entry of the same kind, and inclusions covered by an
+// exclusion. It returns how many were dropped.
+func (m *Scope) Minimize() int {
+	n := m.Deduplicate()
+	includes, excludes := newCoverIndex(m.entries, false), newCoverIndex(m.entries, true)
+	kept := m.entries[:0:0]
+	for j, e := range m.entries {
+		if e.exclude {
+			if excludes.covering(j) >= 0 {
+				continue
+			}
+		} else if includes.covering(j) >= 0 || excludes.covering(j) >= 0 {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return n + m.keep(kept)
+}
+
+func (m *Scope) keep(entries []scopeEntry) int {
+	n := len(m.entries) - len(entries)
+	m.entries = entries
+	m.buildIndexes()
+	return n
+}
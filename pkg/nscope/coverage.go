@@ -0,0 +1,21 @@
+package nscope
+
+func (m *Scope) markUsed(t target) {
+	mark := func(i int) bool {
+		m.used[i].Store(true)
+		return true
+	}
+	m.excludes.each(t, m.entries, mark)
+	m.index.each(t, m.entries, mark)
+}
+
+func (m *Scope) UnusedRules() []Rule {
+	var unused []Rule
+	for i := range m.entries {
+		if m.used[i].Load() || m.entries[i].expiredAt(m.asOf) {
+			continue
+		}
+		unused = append(unused, m.ruleAt(i))
+	}
+	return unused
+}
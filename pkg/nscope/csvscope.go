@@ -0,0 +1,109 @@
+package nscope
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type CSVColumns struct {
+	Host       []string
+	Type       []string
+	InScope    []string
+	Tier       []string
+	Subdomains []string
+}
+
+var bugcrowdColumns = CSVColumns{
+	Host:    []string{"uri", "url", "target", "name"},
+	Type:    []string{"category", "type"},
+	InScope: []string{"in_scope", "in scope", "scope", "status"},
+}
+
+var intigritiColumns = CSVColumns{
+	Host: []string{"endpoint", "content", "domain", "asset"},
+	Type: []string{"type"},
+	Tier: []string{"tier", "bounty tier"},
+}
+
+func readCSVAssets(r io.Reader, cols CSVColumns) ([]scopeAsset, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	host := csvColumn(header, cols.Host)
+	if host == -1 {
+		return nil, fmt.Errorf("no host column (looked for %s)", strings.Join(cols.Host, ", "))
+	}
+	kind, inScope, tier := csvColumn(header, cols.Type), csvColumn(header, cols.InScope), csvColumn(header, cols.Tier)
+	subdomains := csvColumn(header, cols.Subdomains)
+	var out []scopeAsset
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		a := scopeAsset{Identifier: csvField(rec, host), Type: csvField(rec, kind), InScope: true}
+		if inScope != -1 {
+			a.InScope = truthy(csvField(rec, inScope))
+		}
+		if subdomains != -1 && truthy(csvField(rec, subdomains)) && !strings.HasPrefix(a.Identifier, "*.") {
+			a.Identifier = "*." + a.Identifier
+		}
+		if tier != -1 {
+			a.setTier(csvField(rec, tier))
+		}
+		if a.Identifier != "" {
+			out = append(out, a)
+		}
+	}
+}
+
+func csvColumn(header, names []string) int {
+	for _, n := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), n) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func csvField(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+func truthy(v string) bool {
+	switch strings.ToLower(strings.NewReplacer("-", " ", "_", " ").Replace(strings.TrimSpace(v))) {
+	case "true", "yes", "y", "1", "in scope", "in", "eligible":
+		return true
+	}
+	return false
+}
+
+func ParseBugcrowdCSV(b []byte, file string) (*Scope, error) {
+	return ParseAssetCSV(b, file, bugcrowdColumns)
+}
+
+func ParseIntigritiCSV(b []byte, file string) (*Scope, error) {
+	return ParseAssetCSV(b, file, intigritiColumns)
+}
+
+func ParseAssetCSV(b []byte, file string, cols CSVColumns) (*Scope, error) {
+	assets, err := readCSVAssets(strings.NewReader(string(b)), cols)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return readScope(strings.NewReader(assetScopeText(assets)), file, LoadOptions{})
+}
@@ -0,0 +1,278 @@
+package nscope
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type OutputEncoder interface {
+	Start(w io.Writer)
+	Encode(Result) error
+	Finish() error
+}
+
+var encoders = map[string]func(Options) OutputEncoder{
+	"plain": func(opts Options) OutputEncoder { return newPlainEncoder(opts) },
+	"json":  func(Options) OutputEncoder { return &jsonEncoder{} },
+	"csv":   func(opts Options) OutputEncoder { return newCSVEncoder(opts) },
+	"count": func(Options) OutputEncoder { return &countEncoder{} },
+	"etld1": func(Options) OutputEncoder { return &etld1Encoder{} },
+}
+
+func RegisterEncoder(name string, f func(Options) OutputEncoder) {
+	encoders[name] = f
+}
+
+func NewEncoder(name string, opts Options) (OutputEncoder, error) {
+	f, ok := encoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (available: %s)", name, strings.Join(EncoderNames(), ", "))
+	}
+	return f(opts), nil
+}
+
+func EncoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for n := range encoders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type plainEncoder struct {
+	w         io.Writer
+	annotate  bool
+	explain   bool
+	onlyHosts bool
+	withPort  bool
+	nul       bool
+	numbered  bool
+	source    func(lineNo int) (string, int)
+	color     bool
+}
+
+const (
+	colorIncluded = "\x1b[1;32m"
+	colorRejected = "\x1b[1;31m"
+	colorDim      = "\x1b[2m"
+	colorReset    = "\x1b[0m"
+)
+
+func newPlainEncoder(opts Options) *plainEncoder {
+	return &plainEncoder{annotate: opts.Annotate, explain: opts.Explain, onlyHosts: opts.OnlyMatching, withPort: opts.WithPort, nul: opts.NulSeparated, numbered: opts.LineNumbers, source: opts.LineSource, color: opts.Color}
+}
+
+func (e *plainEncoder) Start(w io.Writer) { e.w = w }
+
+func (e *plainEncoder) Encode(res Result) error {
+	prefix := ""
+	if len(res.Scopes) > 0 {
+		prefix = strings.Join(res.Scopes, ",") + "\t"
+	}
+	if e.numbered {
+		prefix += e.location(res.LineNo)
+	}
+	if e.onlyHosts {
+		hosts := res.Matches
+		if len(hosts) == 0 {
+			host := res.Host
+			if e.withPort && res.Port != "" {
+				host = net.JoinHostPort(host, res.Port)
+			}
+			hosts = []string{host}
+		}
+		for _, h := range hosts {
+			if e.color {
+				h = highlight(h, []string{h}, res.Verdict)
+			}
+			if err := e.write(prefix + h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	line := res.Line
+	if e.color {
+		hosts := res.Matches
+		if len(hosts) == 0 {
+			hosts = []string{res.Host}
+		}
+		line = highlight(line, hosts, res.Verdict)
+	}
+	line = prefix + line
+	if e.annotate && len(res.Hits) > 0 {
+		line += " [" + strings.Join(res.Hits, ",") + "]"
+	}
+	switch {
+	case e.color && res.Rule.Index >= 0:
+		line += "\t" + colorDim + res.Rule.Describe() + colorReset
+	case e.explain:
+		line += "\t" + res.Rule.Describe()
+	}
+	return e.write(line)
+}
+
+// highlight colors the first occurrence of each host in line, green when the
+// line is in scope and red otherwise. Hosts that do not appear literally, such
+// as a normalized IDN, are left alone.
+func highlight(line string, hosts []string, v Verdict) string {
+	color := colorRejected
+	if v == Included {
+		color = colorIncluded
+	}
+	lower := strings.ToLower(line)
+	type span struct{ start, end int }
+	var spans []span
+	for _, h := range hosts {
+		if i := strings.Index(lower, h); i >= 0 && len(lower) == len(line) {
+			spans = append(spans, span{i, i + len(h)})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		b.WriteString(line[last:s.start])
+		b.WriteString(color + line[s.start:s.end] + colorReset)
+		last = s.end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// location returns the grep-style "line:" or "file:line:" prefix of a line.
+func (e *plainEncoder) location(lineNo int) string {
+	if e.source != nil {
+		if name, n := e.source(lineNo); name != "" {
+			return name + ":" + strconv.Itoa(n) + ":"
+		}
+	}
+	return strconv.Itoa(lineNo) + ":"
+}
+
+func (e *plainEncoder) write(s string) error {
+	end := "\n"
+	if e.nul {
+		end = "\x00"
+	}
+	_, err := io.WriteString(e.w, s+end)
+	return err
+}
+
+func (e *plainEncoder) Finish() error { return nil }
+
+type jsonResult struct {
+	LineNo    int      `json:"line_no"`
+	Input     string   `json:"input"`
+	Host      string   `json:"host"`
+	Port      string   `json:"port,omitempty"`
+	Verdict   string   `json:"verdict"`
+	Matched   bool     `json:"matched"`
+	Rule      string   `json:"rule,omitempty"`
+	RuleIndex int      `json:"rule_index"`
+	Tags      []string `json:"tags,omitempty"`
+	Note      string   `json:"note,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+	Hits      []string `json:"hits,omitempty"`
+	Matches   []string `json:"matches,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) Start(w io.Writer) {
+	e.enc = json.NewEncoder(w)
+	e.enc.SetEscapeHTML(false)
+}
+
+func (e *jsonEncoder) Encode(res Result) error {
+	return e.enc.Encode(jsonResult{
+		LineNo:    res.LineNo,
+		Input:     res.Line,
+		Host:      res.Host,
+		Port:      res.Port,
+		Verdict:   res.Verdict.String(),
+		Matched:   res.Verdict == Included,
+		Rule:      res.Rule.Text,
+		RuleIndex: res.Rule.Index,
+		Tags:      res.Rule.Tags,
+		Note:      res.Rule.Note,
+		Severity:  res.Rule.Severity,
+		Hits:      res.Hits,
+		Matches:   res.Matches,
+		Scopes:    res.Scopes,
+	})
+}
+
+func (e *jsonEncoder) Finish() error { return nil }
+
+type csvEncoder struct {
+	cw      *csv.Writer
+	explain bool
+	scopes  bool
+}
+
+func newCSVEncoder(opts Options) *csvEncoder {
+	return &csvEncoder{explain: opts.Explain, scopes: len(opts.Scopes) > 0}
+}
+
+func (e *csvEncoder) Start(w io.Writer) {
+	e.cw = csv.NewWriter(w)
+	header := []string{"line_no", "input", "host", "port", "verdict"}
+	if e.explain {
+		header = append(header, "rule")
+	}
+	if e.scopes {
+		header = append(header, "scopes")
+	}
+	e.cw.Write(header)
+}
+
+func (e *csvEncoder) Encode(res Result) error {
+	record := []string{strconv.Itoa(res.LineNo), res.Line, res.Host, res.Port, res.Verdict.String()}
+	if e.explain {
+		record = append(record, res.Rule.Describe())
+	}
+	if e.scopes {
+		record = append(record, strings.Join(res.Scopes, ","))
+	}
+	if err := e.cw.Write(record); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+func (e *csvEncoder) Finish() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+type countEncoder struct {
+	w io.Writer
+	n int
+}
+
+func (e *countEncoder) Start(w io.Writer) { e.w = w }
+
+func (e *countEncoder) Encode(Result) error {
+	e.n++
+	return nil
+}
+
+func (e *countEncoder) Finish() error {
+	_, err := fmt.Fprintln(e.w, e.n)
+	return err
+}
@@ -0,0 +1,120 @@
+package nscope
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+func (e scopeEntry) canonical() string {
+	var h string
+	switch e.kind {
+	case scopeLeadingWildcard:
+		h = "*." + e.base
+	case scopePatternWildcard:
+		h = strings.Join(e.patternLabels, ".")
+	case scopeRegex:
+		h = "re:" + e.base
+	case scopeASN:
+		h = "as:" + e.base
+	case scopeTLDWildcard:
+		h = e.base + ".*"
+	case scopeAny:
+		h = "*"
+	default:
+		h = e.base
+	}
+	if e.port != "" {
+		h = net.JoinHostPort(h, e.port)
+	} else if e.scheme != "" && strings.Contains(h, ":") {
+		h = "[" + h + "]"
+	}
+	if e.scheme != "" {
+		h = e.scheme + "://" + h
+	}
+	h += e.path
+	if e.exclude {
+		return "!" + h
+	}
+	return h
+}
+
+func (e scopeEntry) label() string {
+	if e.raw != "" {
+		return e.raw
+	}
+	return e.canonical()
+}
+
+func (e scopeEntry) location() string {
+	if e.file == "" {
+		return fmt.Sprintf("line %d", e.line)
+	}
+	return fmt.Sprintf("%s:%d", e.file, e.line)
+}
+
+func (e scopeEntry) notes(asOf time.Time) []string {
+	var notes []string
+	if e.expiredAt(asOf) {
+		notes = append(notes, "expired "+e.info().expires.Format(time.RFC3339)+", not matched")
+	}
+	if e.idnaFailed {
+		notes = append(notes, "IDNA conversion failed, raw text kept")
+	}
+	if e.altered {
+		notes = append(notes, "changed by normalization")
+	}
+	return notes
+}
+
+func (m *Scope) Explain(w io.Writer) {
+	for _, e := range m.entries {
+		fmt.Fprintf(w, "%s: %s -> %s [%s]", e.location(), e.raw, e.canonical(), e.kind)
+		if notes := e.notes(m.asOf); len(notes) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(notes, "; "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteNormalized writes the scope as a text scope file with every entry in
+// the form it is matched in, with ports sorted and merged, followed by a
+// comment giving its kind and tags. With sorted set the entries are sorted
+// and exact repeats dropped, so the output of two equivalent scopes compares
+// equal.
+func (m *Scope) WriteNormalized(w io.Writer, sorted bool) error {
+	lines := make([]string, 0, len(m.entries))
+	for _, e := range m.entries {
+		if len(e.ports) > 0 {
+			e.port = formatPortSpec(e.ports)
+		}
+		line := e.canonical() + " # " + e.kind.String()
+		for _, t := range e.info().tags {
+			line += " tag:" + t
+		}
+		lines = append(lines, line)
+	}
+	if sorted {
+		slices.Sort(lines)
+		lines = slices.Compact(lines)
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Scope) WriteNotices(w io.Writer) {
+	for _, e := range m.entries {
+		notes := e.notes(m.asOf)
+		if len(notes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "notice: %s: %q -> %q: %s\n", e.location(), e.raw, e.canonical(), strings.Join(notes, "; "))
+	}
+}
@@ -0,0 +1,212 @@
+package nscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"time"
+)
+
+const scopeDocumentVersion = 1
+
+type scopeDocument struct {
+	Version int         `json:"version"`
+	Entries []entryJSON `json:"entries"`
+}
+
+type entryJSON struct {
+	Raw        string   `json:"raw"`
+	Kind       string   `json:"kind"`
+	Base       string   `json:"base,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Prefixes   []string `json:"prefixes,omitempty"`
+	Port       string   `json:"port,omitempty"`
+	Scheme     string   `json:"scheme,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Note       string   `json:"note,omitempty"`
+	Severity   string   `json:"severity,omitempty"`
+	Expires    string   `json:"expires,omitempty"`
+	File       string   `json:"file,omitempty"`
+	Line       int      `json:"line,omitempty"`
+	Altered    bool     `json:"altered,omitempty"`
+	IDNAFailed bool     `json:"idna_failed,omitempty"`
+	Exclude    bool     `json:"exclude,omitempty"`
+	NoApex     bool     `json:"no_apex,omitempty"`
+}
+
+func (k scopeKind) String() string {
+	switch k {
+	case scopeExact:
+		return "exact"
+	case scopeLeadingWildcard:
+		return "leading_wildcard"
+	case scopePatternWildcard:
+		return "pattern_wildcard"
+	case scopeCIDR:
+		return "cidr"
+	case scopeRange:
+		return "range"
+	case scopeRegex:
+		return "regex"
+	case scopeASN:
+		return "asn"
+	case scopeTLDWildcard:
+		return "tld_wildcard"
+	case scopeAny:
+		return "any"
+	}
+	return fmt.Sprintf("scopeKind(%d)", int(k))
+}
+
+func parseScopeKind(s string) (scopeKind, error) {
+	switch s {
+	case "exact":
+		return scopeExact, nil
+	case "leading_wildcard":
+		return scopeLeadingWildcard, nil
+	case "pattern_wildcard":
+		return scopePatternWildcard, nil
+	case "cidr":
+		return scopeCIDR, nil
+	case "range":
+		return scopeRange, nil
+	case "regex":
+		return scopeRegex, nil
+	case "asn":
+		return scopeASN, nil
+	case "tld_wildcard":
+		return scopeTLDWildcard, nil
+	case "any":
+		return scopeAny, nil
+	}
+	return 0, fmt.Errorf("unknown entry kind %q", s)
+}
+
+func (m *Scope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.document())
+}
+
+func (m *Scope) document() scopeDocument {
+	doc := scopeDocument{Version: scopeDocumentVersion, Entries: make([]entryJSON, 0, len(m.entries))}
+	for _, e := range m.entries {
+		meta := e.info()
+		var prefixes []string
+		if e.kind == scopeASN {
+			for _, p := range meta.prefixes {
+				prefixes = append(prefixes, p.String())
+			}
+		}
+		var expires string
+		if !meta.expires.IsZero() {
+			expires = meta.expires.Format(time.RFC3339)
+		}
+		doc.Entries = append(doc.Entries, entryJSON{
+			Raw:        e.raw,
+			Kind:       e.kind.String(),
+			Base:       e.base,
+			Labels:     e.patternLabels,
+			Prefixes:   prefixes,
+			Port:       e.port,
+			Scheme:     e.scheme,
+			Path:       e.path,
+			Tags:       meta.tags,
+			Note:       meta.note,
+			Severity:   meta.severity,
+			Expires:    expires,
+			File:       e.file,
+			Line:       e.line,
+			Altered:    e.altered,
+			IDNAFailed: e.idnaFailed,
+			Exclude:    e.exclude,
+			NoApex:     e.noApex,
+		})
+	}
+	return doc
+}
+
+func (m *Scope) UnmarshalJSON(b []byte) error {
+	var doc scopeDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return m.setDocument(doc)
+}
+
+func (m *Scope) setDocument(doc scopeDocument) error {
+	if doc.Version != scopeDocumentVersion {
+		return fmt.Errorf("unsupported scope document version %d", doc.Version)
+	}
+	entries := make([]scopeEntry, 0, len(doc.Entries))
+	for i, ej := range doc.Entries {
+		kind, err := parseScopeKind(ej.Kind)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		var network netip.Prefix
+		var prefixes []netip.Prefix
+		var re *regexp.Regexp
+		var ports []portRange
+		if ej.Port != "" {
+			if ports, err = parsePortSpec(ej.Port); err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		switch kind {
+		case scopeRegex:
+			if re, err = regexp.Compile(ej.Base); err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+		case scopeCIDR:
+			if network, err = netip.ParsePrefix(ej.Base); err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+		case scopeRange:
+			r, ok := parseRangeEntry(ej.Base)
+			if !ok {
+				return fmt.Errorf("entry %d: invalid IP range %q", i, ej.Base)
+			}
+			prefixes = r.info().prefixes
+		case scopeASN:
+			for _, s := range ej.Prefixes {
+				p, err := netip.ParsePrefix(s)
+				if err != nil {
+					return fmt.Errorf("entry %d: %w", i, err)
+				}
+				prefixes = append(prefixes, p)
+			}
+		}
+		var expires time.Time
+		if ej.Expires != "" {
+			if expires, err = time.Parse(time.RFC3339, ej.Expires); err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		var meta *entryMeta
+		if re != nil || prefixes != nil || ej.Tags != nil || ej.Note != "" || ej.Severity != "" || !expires.IsZero() {
+			meta = &entryMeta{re: re, prefixes: prefixes, tags: ej.Tags, note: ej.Note, severity: ej.Severity, expires: expires}
+		}
+		entries = append(entries, scopeEntry{
+			raw:           ej.Raw,
+			network:       network,
+			meta:          meta,
+			ports:         ports,
+			kind:          kind,
+			base:          ej.Base,
+			port:          ej.Port,
+			scheme:        ej.Scheme,
+			path:          ej.Path,
+			patternLabels: ej.Labels,
+			file:          ej.File,
+			line:          ej.Line,
+			altered:       ej.Altered,
+			idnaFailed:    ej.IDNAFailed,
+			exclude:       ej.Exclude,
+			noApex:        ej.NoApex,
+		})
+	}
+	m.entries = entries
+	m.buildIndexes()
+	return nil
+}
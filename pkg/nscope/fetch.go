@@ -0,0 +1,263 @@
+package nscope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const fetchTimeout = 30 * time.Second
+
+var intigritiIDRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type scopeAsset struct {
+	Identifier string
+	Type       string
+	InScope    bool
+	Tags       []string
+}
+
+func (a *scopeAsset) setTier(tier string) {
+	t := strings.ToLower(strings.Join(strings.Fields(tier), ""))
+	switch t {
+	case "":
+	case "outofscope":
+		a.InScope = false
+	default:
+		a.Tags = append(a.Tags, t)
+	}
+}
+
+var scopeFetchers = map[string]func(ctx context.Context, program string) ([]scopeAsset, error){
+	"h1":        fetchHackerOne,
+	"bugcrowd":  fetchBugcrowd,
+	"intigriti": fetchIntigriti,
+}
+
+var (
+	h1API        = "https://api.hackerone.com/v1/hackers/programs/"
+	bugcrowdWeb  = "https://bugcrowd.com"
+	intigritiAPI = "https://api.intigriti.com/external/researcher/v1/programs"
+)
+
+type h1Scopes struct {
+	Data []struct {
+		Attributes struct {
+			AssetType             string `json:"asset_type"`
+			AssetIdentifier       string `json:"asset_identifier"`
+			EligibleForSubmission bool   `json:"eligible_for_submission"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func fetchHackerOne(ctx context.Context, program string) ([]scopeAsset, error) {
+	user, token := os.Getenv("H1_API_USERNAME"), os.Getenv("H1_API_TOKEN")
+	if user == "" || token == "" {
+		return nil, fmt.Errorf("H1_API_USERNAME and H1_API_TOKEN must be set")
+	}
+	next := h1API + url.PathEscape(program) + "/structured_scopes?page%5Bsize%5D=100"
+	var out []scopeAsset
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(user, token)
+		req.Header.Set("Accept", "application/json")
+		var page h1Scopes
+		if err := getJSON(req, &page); err != nil {
+			return nil, err
+		}
+		for _, d := range page.Data {
+			a := d.Attributes
+			out = append(out, scopeAsset{Identifier: a.AssetIdentifier, Type: a.AssetType, InScope: a.EligibleForSubmission})
+		}
+		next = page.Links.Next
+	}
+	return out, nil
+}
+
+type bugcrowdGroups struct {
+	Groups []struct {
+		Name       string `json:"name"`
+		InScope    bool   `json:"in_scope"`
+		TargetsURL string `json:"targets_url"`
+	} `json:"groups"`
+}
+
+type bugcrowdTargets struct {
+	Targets []struct {
+		Name     string `json:"name"`
+		URI      string `json:"uri"`
+		Category string `json:"category"`
+	} `json:"targets"`
+}
+
+func fetchBugcrowd(ctx context.Context, program string) ([]scopeAsset, error) {
+	get := func(path string, v any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, bugcrowdWeb+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if session := os.Getenv("BUGCROWD_SESSION"); session != "" {
+			req.AddCookie(&http.Cookie{Name: "_crowdcontrol_session", Value: session})
+		}
+		return getJSON(req, v)
+	}
+	var groups bugcrowdGroups
+	if err := get("/"+url.PathEscape(program)+"/target_groups", &groups); err != nil {
+		return nil, err
+	}
+	var out []scopeAsset
+	for _, g := range groups.Groups {
+		var targets bugcrowdTargets
+		if err := get(g.TargetsURL, &targets); err != nil {
+			return nil, fmt.Errorf("target group %q: %w", g.Name, err)
+		}
+		for _, t := range targets.Targets {
+			id := t.URI
+			if id == "" {
+				id = t.Name
+			}
+			out = append(out, scopeAsset{Identifier: id, Type: t.Category, InScope: g.InScope})
+		}
+	}
+	return out, nil
+}
+
+type intigritiPrograms struct {
+	MaxCount int `json:"maxCount"`
+	Records  []struct {
+		ID     string `json:"id"`
+		Handle string `json:"handle"`
+	} `json:"records"`
+}
+
+type intigritiProgram struct {
+	Domains struct {
+		Content []struct {
+			Type struct {
+				Value string `json:"value"`
+			} `json:"type"`
+			Endpoint string `json:"endpoint"`
+			Tier     struct {
+				Value string `json:"value"`
+			} `json:"tier"`
+		} `json:"content"`
+	} `json:"domains"`
+}
+
+func fetchIntigriti(ctx context.Context, program string) ([]scopeAsset, error) {
+	token := os.Getenv("INTIGRITI_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("INTIGRITI_TOKEN must be set")
+	}
+	get := func(u string, v any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return getJSON(req, v)
+	}
+	id := program
+	if !intigritiIDRe.MatchString(program) {
+		id = ""
+		for offset := 0; id == ""; {
+			var page intigritiPrograms
+			if err := get(fmt.Sprintf("%s?limit=500&offset=%d", intigritiAPI, offset), &page); err != nil {
+				return nil, err
+			}
+			for _, r := range page.Records {
+				if strings.EqualFold(r.Handle, program) {
+					id = r.ID
+				}
+			}
+			offset += len(page.Records)
+			if id == "" && (len(page.Records) == 0 || offset >= page.MaxCount) {
+				return nil, fmt.Errorf("no program with handle %q", program)
+			}
+		}
+	}
+	var p intigritiProgram
+	if err := get(intigritiAPI+"/"+url.PathEscape(id), &p); err != nil {
+		return nil, err
+	}
+	var out []scopeAsset
+	for _, d := range p.Domains.Content {
+		a := scopeAsset{Identifier: d.Endpoint, Type: d.Type.Value, InScope: true}
+		a.setTier(d.Tier.Value)
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func getJSON(req *http.Request, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func FetchScopeText(platform, program string) (string, error) {
+	fetch := scopeFetchers[platform]
+	if fetch == nil {
+		return "", fmt.Errorf("unknown scope platform %q", platform)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	assets, err := fetch(ctx, program)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s scope for %s: %w", platform, program, err)
+	}
+	return assetScopeText(assets), nil
+}
+
+func assetScopeText(assets []scopeAsset) string {
+	var b strings.Builder
+	for _, a := range assets {
+		switch strings.ToUpper(a.Type) {
+		case "", "URL", "WILDCARD", "DOMAIN", "CIDR", "IP_ADDRESS", "IP_RANGE", "IPRANGE", "API", "WEBSITE", "NETWORK":
+		default:
+			fmt.Fprintf(&b, "# skipped %s asset %s\n", a.Type, a.Identifier)
+			continue
+		}
+		for _, id := range strings.FieldsFunc(a.Identifier, func(r rune) bool { return r == ' ' || r == '\n' || r == '\t' }) {
+			id = strings.TrimSuffix(id, ",")
+			if !strings.ContainsAny(id, ".:") {
+				continue
+			}
+			if !a.InScope {
+				id = "!" + id
+			}
+			if _, err := parseScopeLine(id); err != nil {
+				fmt.Fprintf(&b, "# skipped %s asset %s: %v\n", a.Type, id, err)
+				continue
+			}
+			b.WriteString(id)
+			for _, t := range a.Tags {
+				b.WriteString(" # tag:" + t)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package nscope
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type harEntry struct {
+	Request struct {
+		URL string `json:"url"`
+	} `json:"request"`
+}
+
+// classifyURL matches one URL taken from a structured input, reporting false
+// when no host could be extracted from it.
+func (m *Scope) classifyURL(url string, opts Options, st *Stats) (Verdict, bool) {
+	st.Lines++
+	res := Result{LineNo: st.Lines, Line: url}
+	opts.Input, opts.Grep, opts.Field, opts.JSONField = "", false, 0, ""
+	if !PrepareLine(&res, opts, st) {
+		return Unmatched, false
+	}
+	m.Classify(&res, opts)
+	st.Count(res.Verdict)
+	return res.Verdict, true
+}
+
+func ProcessHAR(r io.Reader, w io.Writer, scope *Scope, opts Options, urlsOnly bool) (Stats, error) {
+	var st Stats
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return st, fmt.Errorf("reading HAR: %w", err)
+	}
+	var log map[string]json.RawMessage
+	if err := json.Unmarshal(doc["log"], &log); err != nil {
+		return st, fmt.Errorf("reading HAR log: %w", err)
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(log["entries"], &entries); err != nil {
+		return st, fmt.Errorf("reading HAR entries: %w", err)
+	}
+	kept := make([]json.RawMessage, 0, len(entries))
+	for _, raw := range entries {
+		var e harEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return st, fmt.Errorf("reading HAR entry: %w", err)
+		}
+		if v, ok := scope.classifyURL(e.Request.URL, opts, &st); !ok || (v == Included) == opts.Reverse {
+			continue
+		}
+		if urlsOnly {
+			if _, err := fmt.Fprintln(w, e.Request.URL); err != nil {
+				return st, err
+			}
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	if urlsOnly {
+		return st, nil
+	}
+	var err error
+	if log["entries"], err = marshalRaw(kept); err != nil {
+		return st, err
+	}
+	if doc["log"], err = marshalRaw(log); err != nil {
+		return st, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return st, enc.Encode(doc)
+}
+
+func marshalRaw(v any) (json.RawMessage, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
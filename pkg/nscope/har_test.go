@@ -0,0 +1,50 @@
+package nscope
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHARInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+	process := func(urlsOnly bool) func(io.Reader, io.Writer, *Scope, Options) (Stats, error) {
+		return func(r io.Reader, w io.Writer, m *Scope, opts Options) (Stats, error) {
+			return ProcessHAR(r, w, m, opts, urlsOnly)
+		}
+	}
+	rejects := map[string]int{RejectBadURL: 1, RejectEmpty: 1}
+
+	got, st := processFixture(t, "session.har", scope, Options{Input: "har"}, process(false))
+	var doc struct {
+		Log struct {
+			Version string
+			Entries []harEntry
+		}
+	}
+	if err := json.Unmarshal([]byte(strings.Join(got, "\n")), &doc); err != nil {
+		t.Fatalf("filtered HAR does not decode: %v", err)
+	}
+	var urls []string
+	for _, e := range doc.Log.Entries {
+		urls = append(urls, e.Request.URL)
+	}
+	if doc.Log.Version != "1.2" {
+		t.Errorf("filtered HAR lost the log version")
+	}
+	checkFixture(t, urls, st, []string{"https://app.example.com/login", "http://10.1.2.3:8080/api"}, rejects)
+
+	got, st = processFixture(t, "session.har", scope, Options{Input: "har"}, process(true))
+	checkFixture(t, got, st, []string{"https://app.example.com/login", "http://10.1.2.3:8080/api"}, rejects)
+	got, st = processFixture(t, "session.har", scope, Options{Input: "har", Reverse: true}, process(true))
+	checkFixture(t, got, st, []string{"https://other.org/track.js"}, rejects)
+
+	m, err := ParseScope(strings.NewReader(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessHAR(strings.NewReader(`{"log": {"entries": {}}}`), io.Discard, m, Options{}, false); err == nil {
+		t.Error("malformed HAR entries were accepted")
+	}
+}
@@ -0,0 +1,332 @@
+package nscope
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	errEmptyHost  = errors.New("empty host")
+	errHostSyntax = errors.New("invalid character in host")
+)
+
+func CanonicalHost(h string) (string, error) {
+	h = strings.TrimSpace(h)
+	if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+		h = stripBrackets(h)
+	}
+	c, err := normalizeHost(h)
+	if err != nil {
+		return "", err
+	}
+	if c == "" {
+		return "", errEmptyHost
+	}
+	return c, nil
+}
+
+func HostsEqual(a, b string) bool {
+	ca, errA := CanonicalHost(a)
+	cb, errB := CanonicalHost(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return ca == cb
+}
+
+type extracted struct {
+	host     string
+	port     string
+	scheme   string
+	path     string
+	fallback bool
+	badURL   bool
+}
+
+func extractHostFromLine(line string) (extracted, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return extracted{}, false
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return extracted{}, false
+	}
+	first := fields[0]
+	if strings.Contains(first, "://") {
+		u, err := url.Parse(first)
+		if err != nil {
+			authority, ok := sliceAuthority(first)
+			if !ok {
+				return extracted{}, false
+			}
+			h, p := splitHostPort(authority)
+			scheme, rest, _ := strings.Cut(first, "://")
+			var path string
+			if i := strings.IndexByte(rest, '/'); i != -1 {
+				path = rest[i:]
+				if end := strings.IndexAny(path, "?#"); end != -1 {
+					path = path[:end]
+				}
+			}
+			return extracted{host: h, port: p, scheme: strings.ToLower(scheme), path: path, fallback: true, badURL: !validAuthority(authority)}, true
+		}
+		if u.Host == "" {
+			return extracted{}, false
+		}
+		h, p := splitHostPort(trimHostPunct(u.Host))
+		return extracted{host: h, port: p, scheme: u.Scheme, path: u.Path}, true
+	}
+	if strings.HasPrefix(first, "[") && strings.Contains(first, "]") {
+		h, p := splitHostPort(first)
+		return extracted{host: h, port: p}, true
+	}
+	if strings.Contains(first, "/") {
+		u, err := url.Parse("http://" + first)
+		if err == nil && u.Host != "" {
+			h, p := splitHostPort(u.Host)
+			return extracted{host: h, port: p, path: u.Path}, true
+		}
+	}
+	h, p := splitHostPort(first)
+	return extracted{host: h, port: p}, true
+}
+
+var grepHostRe = regexp.MustCompile(`(?i)^(?:[a-z0-9_](?:[a-z0-9_-]*[a-z0-9])?\.)+(?:[a-z][a-z0-9-]*[a-z0-9]|xn--[a-z0-9-]+)$`)
+
+func isGrepSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("\"'`<>(){}[],;|=", r)
+}
+
+func extractAllHosts(line string) []extracted {
+	var out []extracted
+	seen := make(map[string]bool)
+	for _, tok := range strings.FieldsFunc(line, isGrepSeparator) {
+		tok = strings.Trim(tok, ".:!?*")
+		if tok == "" || (!strings.Contains(tok, ".") && !strings.Contains(tok, ":")) {
+			continue
+		}
+		ex, ok := extractHostFromLine(tok)
+		if !ok || ex.badURL {
+			continue
+		}
+		h := strings.TrimRight(ex.host, ".")
+		if net.ParseIP(h) == nil && !grepHostRe.MatchString(h) {
+			continue
+		}
+		if key := h + " " + ex.port + " " + ex.scheme + " " + ex.path; !seen[key] {
+			seen[key] = true
+			out = append(out, ex)
+		}
+	}
+	return out
+}
+
+func splitHostPort(s string) (string, string) {
+	h, p := stripPort(s)
+	if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+		h = stripBrackets(h)
+	}
+	return h, p
+}
+
+func sliceAuthority(s string) (string, bool) {
+	idx := strings.Index(s, "://")
+	if idx == -1 {
+		return "", false
+	}
+	a := s[idx+3:]
+	if end := strings.IndexAny(a, "/?#"); end != -1 {
+		a = a[:end]
+	}
+	if at := strings.LastIndexByte(a, '@'); at != -1 {
+		a = a[at+1:]
+	}
+	a = trimHostPunct(a)
+	return a, a != ""
+}
+
+// validAuthority reports whether a, the authority sliced out of a URL that
+// did not parse, is a host and optional port: brackets must be balanced and
+// hold an IPv6 address, and names may only hold host name characters.
+func validAuthority(a string) bool {
+	h, port := a, ""
+	if rest, ok := strings.CutPrefix(a, "["); ok {
+		end := strings.IndexByte(rest, ']')
+		if end == -1 {
+			return false
+		}
+		h, port = rest[:end], rest[end+1:]
+		if port != "" && port[0] != ':' {
+			return false
+		}
+		if addr, _, _ := strings.Cut(h, "%"); !strings.Contains(addr, ":") || net.ParseIP(addr) == nil {
+			return false
+		}
+	} else {
+		if strings.ContainsAny(a, "[]") {
+			return false
+		}
+		if i := strings.LastIndexByte(a, ':'); i != -1 {
+			h, port = a[:i], a[i:]
+		}
+		if !validHostChars(strings.ToLower(h)) {
+			return false
+		}
+	}
+	for _, c := range strings.TrimPrefix(port, ":") {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return h != ""
+}
+
+func trimHostPunct(s string) string {
+	return strings.TrimRight(s, "\"'`,;.)>}|")
+}
+
+func withinHostLimits(h string, maxLen int) bool {
+	if maxLen > 0 && len(h) > maxLen {
+		return false
+	}
+	return strings.Count(h, ".") < maxHostLabels
+}
+
+func isCanonicalASCII(h string) bool {
+	if h == "" || h[len(h)-1] == '.' || strings.Contains(h, "xn--") {
+		return false
+	}
+	for i := 0; i < len(h); i++ {
+		c := h[i]
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func normalizeHost(h string) (string, error) {
+	if isCanonicalASCII(h) {
+		return h, nil
+	}
+	return normalizeHostSlow(h)
+}
+
+func normalizeHostSlow(h string) (string, error) {
+	h = trimHostEnds(h)
+	if h == "" {
+		return "", nil
+	}
+	if ip := net.ParseIP(h); ip != nil {
+		return ip.String(), nil
+	}
+	// Malformed xn-- labels can decode to text that converts differently
+	// again, or to nothing, leaving new dots or spaces at the ends; such
+	// hosts are kept as written so that normalizing is idempotent.
+	h = strings.ToLower(h)
+	if ascii, err := idna.ToASCII(h); err == nil {
+		ascii = strings.ToLower(trimHostEnds(ascii))
+		if again, err := idna.ToASCII(ascii); err == nil && again == ascii {
+			h = ascii
+		}
+	}
+	if ip := net.ParseIP(h); ip != nil {
+		return ip.String(), nil
+	}
+	if !validHostChars(h) {
+		return "", errHostSyntax
+	}
+	return h, nil
+}
+
+// validHostChars reports whether h holds only letters, digits, hyphens,
+// underscores and dots, allowing any printable non-ASCII letters left by a
+// failed IDNA conversion.
+func validHostChars(h string) bool {
+	for _, r := range h {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '-', r == '_', r == '.':
+		case r >= utf8.RuneSelf && unicode.IsPrint(r) && !unicode.IsSpace(r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// trimHostEnds removes surrounding spaces and trailing dots.
+func trimHostEnds(h string) string {
+	return strings.TrimRightFunc(strings.TrimSpace(h), func(r rune) bool { return r == '.' || unicode.IsSpace(r) })
+}
+
+var (
+	defangedScheme = regexp.MustCompile(`(?i)\bh(?:xx|\*\*)p(s?)(\[?:\]?//|\[://\])`)
+	defangedDots   = strings.NewReplacer("[.]", ".", "(.)", ".", "{.}", ".", "[dot]", ".", "(dot)", ".", "{dot}", ".", "[:]", ":")
+)
+
+func Refang(line string) string {
+	if !strings.ContainsAny(line, "[({") && !strings.Contains(line, "xx") && !strings.Contains(line, "XX") {
+		return line
+	}
+	line = defangedScheme.ReplaceAllString(line, "http$1://")
+	return defangedDots.Replace(line)
+}
+
+var strictIDNA = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+func checkIDNA(h string) error {
+	if isCanonicalASCII(h) {
+		return nil
+	}
+	h = strings.TrimRight(strings.TrimSpace(h), ".")
+	if net.ParseIP(h) != nil {
+		return nil
+	}
+	if _, err := strictIDNA.ToASCII(h); err != nil {
+		return fmt.Errorf("host %q rejected by IDNA validation: %w", h, err)
+	}
+	return nil
+}
+
+func stripPort(h string) (string, string) {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return "", ""
+	}
+	if strings.HasPrefix(h, "[") {
+		if idx := strings.LastIndex(h, "]"); idx != -1 {
+			host := h[:idx+1]
+			rest := h[idx+1:]
+			if strings.HasPrefix(rest, ":") {
+				return host, strings.TrimPrefix(rest, ":")
+			}
+			return host, ""
+		}
+	}
+	if host, port, err := net.SplitHostPort(h); err == nil {
+		return host, port
+	}
+	if strings.Count(h, ":") > 1 && net.ParseIP(h) != nil {
+		return h, ""
+	}
+	if idx := strings.LastIndexByte(h, ':'); idx != -1 && net.ParseIP(h[idx+1:]) == nil {
+		return h[:idx], h[idx+1:]
+	}
+	return h, ""
+}
+
+func stripBrackets(s string) string {
+	s = strings.TrimPrefix(s, "[")
+	s = strings.TrimSuffix(s, "]")
+	return s
+}
@@ -0,0 +1,177 @@
+package nscope
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestPrepareLineFallback(t *testing.T) {
+	tests := []struct {
+		line     string
+		host     string
+		port     string
+		path     string
+		fallback bool
+	}{
+		{"https://api.example.com/users/{id}", "api.example.com", "", "/users/{id}", false},
+		{"https://api.example.com/users/{id}/%zz", "api.example.com", "", "/users/{id}/%zz", true},
+		{"https://api.example.com/{id}?q={query}", "api.example.com", "", "/{id}", false},
+		{"http://example.com/search?q=a b", "example.com", "", "/search", false},
+		{"http://example.com/a b/%zz", "example.com", "", "/a", false},
+		{"http://example.com/%zz c", "example.com", "", "/%zz", true},
+		{`https://example.com"`, "example.com", "", "", false},
+		{"https://example.com).", "example.com", "", "", false},
+		{"https://example.com/path\",", "example.com", "", "/path\",", false},
+		{"https://example.com:8443/x'", "example.com", "8443", "/x'", false},
+		{`https://example.com:8443/%zz",`, "example.com", "8443", "/%zz\",", true},
+		{"https://user:p@ss@example.com/%zz", "example.com", "", "/%zz", true},
+		{"http://[::1]:8080/%zz", "::1", "8080", "/%zz", true},
+	}
+	opts := Options{MaxHostLength: DefaultMaxHostLength}
+	for _, tt := range tests {
+		var st Stats
+		res := Result{LineNo: 1, Line: tt.line}
+		if !PrepareLine(&res, opts, &st) {
+			t.Errorf("PrepareLine(%q) found no host", tt.line)
+			continue
+		}
+		if res.Host != tt.host || res.Port != tt.port || res.Path != tt.path {
+			t.Errorf("PrepareLine(%q) = %q %q %q, want %q %q %q", tt.line, res.Host, res.Port, res.Path, tt.host, tt.port, tt.path)
+		}
+		if got := st.Fallback == 1; got != tt.fallback {
+			t.Errorf("PrepareLine(%q) used the fallback: %v, want %v", tt.line, got, tt.fallback)
+		}
+	}
+}
+
+func TestPrepareLineRejects(t *testing.T) {
+	tests := []struct {
+		line   string
+		reason string
+	}{
+		{"http://[::1", RejectBadURL},
+		{"http://%zz/", RejectBadURL},
+		{"http://[zz]/%zz", RejectBadURL},
+		{"http://[::1]x/%zz", RejectBadURL},
+		{"https://exa<mple.com/%zz", RejectBadURL},
+		{"http://example.com:8x/%zz", RejectBadURL},
+		{"http:///path", RejectNoHost},
+		{"foo%bar.example.com", RejectBadHost},
+		{"exa<mple.com", RejectBadHost},
+		{"http://[::1]:8080/%zz", ""},
+		{"https://api.example.com/%zz", ""},
+	}
+	opts := Options{MaxHostLength: DefaultMaxHostLength}
+	for _, tt := range tests {
+		var st Stats
+		res := Result{LineNo: 1, Line: tt.line}
+		ok := PrepareLine(&res, opts, &st)
+		if ok != (tt.reason == "") || res.Reject != tt.reason {
+			t.Errorf("PrepareLine(%q) = %v, reason %q; want reason %q", tt.line, ok, res.Reject, tt.reason)
+		}
+		if tt.reason != "" && (!res.Invalid || st.Invalid != 1 || st.Rejects[tt.reason] != 1) {
+			t.Errorf("PrepareLine(%q) not counted as invalid for %q: %+v", tt.line, tt.reason, st)
+		}
+	}
+}
+
+// hostForms are differently written forms of a few hosts, for checking the
+// properties of CanonicalHost and HostsEqual across every pair.
+var hostForms = []string{
+	"example.com", "Example.COM", "example.com.", " example.com ", "EXAMPLE.com..",
+	"sub.example.com", "xn--bcher-kva.example", "bücher.example", "BÜCHER.example.",
+	"10.0.0.1", "::ffff:10.0.0.1", "[::ffff:10.0.0.1]", "::ffff:a00:1",
+	"2001:db8::1", "2001:DB8:0:0:0:0:0:1", "[2001:db8::1]", "2001:0db8::0001",
+	"_dmarc.example.com", "a-b.example.com", "localhost",
+}
+
+func TestCanonicalHostIdempotent(t *testing.T) {
+	for _, h := range hostForms {
+		c, err := CanonicalHost(h)
+		if err != nil {
+			t.Errorf("CanonicalHost(%q): %v", h, err)
+			continue
+		}
+		if again, err := CanonicalHost(c); err != nil || again != c {
+			t.Errorf("CanonicalHost(%q) = %q, but CanonicalHost(%q) = %q, %v", h, c, c, again, err)
+		}
+	}
+	f := func(h string) bool {
+		c, err := CanonicalHost(h)
+		if err != nil {
+			return true
+		}
+		again, err := CanonicalHost(c)
+		return err == nil && again == c
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 10000, Values: randomHost}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHostsEqualSymmetric(t *testing.T) {
+	for _, a := range hostForms {
+		if !HostsEqual(a, a) {
+			t.Errorf("HostsEqual(%q, %q) = false", a, a)
+		}
+		for _, b := range hostForms {
+			if HostsEqual(a, b) != HostsEqual(b, a) {
+				t.Errorf("HostsEqual(%q, %q) = %v but HostsEqual(%q, %q) = %v", a, b, HostsEqual(a, b), b, a, HostsEqual(b, a))
+			}
+		}
+	}
+	f := func(a, b string) bool { return HostsEqual(a, b) == HostsEqual(b, a) }
+	if err := quick.Check(f, &quick.Config{MaxCount: 10000, Values: randomHost}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHostsEqualMatchesExactEntry(t *testing.T) {
+	for _, a := range hostForms {
+		scope, err := ParseScope(strings.NewReader(a + "\n"))
+		if err != nil {
+			t.Fatalf("ParseScope(%q): %v", a, err)
+		}
+		for _, b := range hostForms {
+			c, err := CanonicalHost(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched, _ := scope.Match(c, ""); matched != HostsEqual(a, b) {
+				t.Errorf("entry %q matching %q = %v, but HostsEqual = %v", a, b, matched, HostsEqual(a, b))
+			}
+		}
+	}
+}
+
+// randomHost fills args with hosts made of labels in mixed case, digits,
+// hyphens, non-ASCII letters and stray dots and spaces.
+func randomHost(args []reflect.Value, r *rand.Rand) {
+	pieces := []string{"a", "Z", "0", "-", "_", ".", ".", " ", "é", "ü", "ß", "xn--", "example", "com", ":", "1", "ffff"}
+	for i := range args {
+		var b strings.Builder
+		for range r.Intn(20) {
+			b.WriteString(pieces[r.Intn(len(pieces))])
+		}
+		args[i] = reflect.ValueOf(b.String())
+	}
+}
+
+func FuzzNormalizeHost(f *testing.F) {
+	for _, h := range hostForms {
+		f.Add(h)
+	}
+	for _, h := range []string{"a_b.example.com", "-a.example.com", "1.2.3.4", "0.0.0.0", "a..b", ".a", "xn--", "::1"} {
+		f.Add(h)
+	}
+	f.Fuzz(func(t *testing.T, h string) {
+		fast, ferr := normalizeHost(h)
+		slow, serr := normalizeHostSlow(h)
+		if fast != slow || (ferr == nil) != (serr == nil) {
+			t.Errorf("normalizeHost(%q) = %q, %v; slow path = %q, %v", h, fast, ferr, slow, serr)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var blankLine = []byte("\r\n\r\n")
+
+func endsRequest(b []byte) bool {
+	return bytes.HasSuffix(b, blankLine) || bytes.HasSuffix(b, []byte("\n\n"))
+}
+
+func requestTarget(req *http.Request) string {
+	if req.URL.IsAbs() {
+		return req.RequestURI
+	}
+	if req.URL.Path == "*" {
+		return req.Host
+	}
+	return req.Host + req.URL.Path
+}
+
+// ProcessHTTPRequests reads raw HTTP requests, one after another as saved by
+// intercepting proxies, and writes back the ones whose Host header and
+// request-target are in scope.
+func ProcessHTTPRequests(r io.Reader, w io.Writer, scope *Scope, opts Options, urlsOnly bool) (Stats, error) {
+	var st Stats
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return st, err
+	}
+	if !endsRequest(doc) {
+		doc = append(doc, blankLine...)
+	}
+	for pos := 0; ; {
+		for pos < len(doc) && (doc[pos] == '\r' || doc[pos] == '\n') {
+			pos++
+		}
+		if pos == len(doc) {
+			return st, nil
+		}
+		rest := bytes.NewReader(doc[pos:])
+		br := bufio.NewReader(rest)
+		req, err := http.ReadRequest(br)
+		if err == nil {
+			_, err = io.Copy(io.Discard, req.Body)
+		}
+		if err != nil {
+			st.Lines++
+			st.Invalid++
+			st.countReject(RejectRecord)
+			if opts.Warnings != nil {
+				fmt.Fprintf(opts.Warnings, "request %d: %v\n", st.Lines, err)
+			}
+			next := bytes.Index(doc[pos:], blankLine)
+			if next == -1 {
+				return st, nil
+			}
+			pos += next + len(blankLine)
+			continue
+		}
+		raw := doc[pos : len(doc)-rest.Len()-br.Buffered()]
+		pos += len(raw)
+		if req.Host == "" {
+			st.Lines++
+			st.Invalid++
+			st.countReject(RejectNoHost)
+			continue
+		}
+		target := requestTarget(req)
+		if v, ok := scope.classifyURL(target, opts, &st); !ok || (v == Included) == opts.Reverse {
+			continue
+		}
+		if urlsOnly {
+			_, err = fmt.Fprintln(w, target)
+		} else {
+			if _, err = w.Write(raw); err == nil && !endsRequest(raw) {
+				_, err = w.Write(blankLine)
+			}
+		}
+		if err != nil {
+			return st, err
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package nscope
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRequestsInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+	process := func(urlsOnly bool) func(io.Reader, io.Writer, *Scope, Options) (Stats, error) {
+		return func(r io.Reader, w io.Writer, m *Scope, opts Options) (Stats, error) {
+			return ProcessHTTPRequests(r, w, m, opts, urlsOnly)
+		}
+	}
+	rejects := map[string]int{RejectRecord: 1, RejectNoHost: 1}
+
+	got, st := processFixture(t, "requests.http", scope, Options{Input: "http"}, process(true))
+	checkFixture(t, got, st, []string{"app.example.com/login", "10.1.2.3:8080/api/items", "admin.example.com/admin"}, rejects)
+	got, st = processFixture(t, "requests.http", scope, Options{Input: "http", Reverse: true}, process(true))
+	checkFixture(t, got, st, []string{"http://other.org/x"}, rejects)
+
+	got, _ = processFixture(t, "requests.http", scope, Options{Input: "http"}, process(false))
+	doc := strings.Join(got, "\n")
+	for _, s := range []string{"GET /login HTTP/1.1\r\nHost: app.example.com\r\n", "Content-Length: 11\r\nContent-Type: application/json\r\n\r\n{\"a\":\"b\"}\r\n", "GET /admin HTTP/1.1\nHost: admin.example.com\n"} {
+		if !strings.Contains(doc, s) {
+			t.Errorf("written requests lack %q", s)
+		}
+	}
+	for _, s := range []string{"other.org", "NOT A REQUEST", "/nohost"} {
+		if strings.Contains(doc, s) {
+			t.Errorf("written requests keep %q", s)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package nscope
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type target struct {
+	host   string
+	port   string
+	scheme string
+	path   string
+}
+
+type scopeIndex struct {
+	names    *labelTrie
+	patterns map[int][]int
+	deep     []int
+	tlds     []int
+	cidrs    map[netip.Prefix][]int
+	bits4    []int
+	bits6    []int
+	regexes  []int
+	any      []int
+}
+
+func newScope(entries []scopeEntry) *Scope {
+	m := &Scope{entries: entries}
+	m.buildIndexes()
+	return m
+}
+
+func (m *Scope) buildIndexes() {
+	m.asOf = time.Now()
+	m.index = buildIndex(m.entries, false, m.asOf)
+	m.excludes = buildIndex(m.entries, true, m.asOf)
+	m.used = make([]atomic.Bool, len(m.entries))
+}
+
+func buildIndex(entries []scopeEntry, exclude bool, asOf time.Time) *scopeIndex {
+	idx := &scopeIndex{
+		names:    newLabelTrie(),
+		patterns: make(map[int][]int),
+		cidrs:    make(map[netip.Prefix][]int),
+	}
+
+	for i, e := range entries {
+		if e.exclude != exclude || e.expiredAt(asOf) {
+			continue
+		}
+		switch e.kind {
+		case scopeExact:
+			idx.names.add(e.base, i, false)
+		case scopeLeadingWildcard:
+			idx.names.add(e.base, i, true)
+		case scopePatternWildcard:
+			if slices.Contains(e.patternLabels, "**") {
+				idx.deep = append(idx.deep, i)
+				break
+			}
+			n := len(e.patternLabels)
+			idx.patterns[n] = append(idx.patterns[n], i)
+		case scopeCIDR:
+			idx.addPrefix(e.network, i)
+		case scopeRange, scopeASN:
+			for _, p := range e.info().prefixes {
+				idx.addPrefix(p, i)
+			}
+		case scopeRegex:
+			idx.regexes = append(idx.regexes, i)
+		case scopeTLDWildcard:
+			idx.tlds = append(idx.tlds, i)
+		case scopeAny:
+			idx.any = append(idx.any, i)
+		}
+	}
+	return idx
+}
+
+func (idx *scopeIndex) addPrefix(p netip.Prefix, i int) {
+	if len(idx.cidrs[p]) == 0 {
+		if p.Addr().Is4() {
+			idx.bits4 = addBits(idx.bits4, p.Bits())
+		} else {
+			idx.bits6 = addBits(idx.bits6, p.Bits())
+		}
+	}
+	idx.cidrs[p] = append(idx.cidrs[p], i)
+}
+
+func addBits(bits []int, b int) []int {
+	i, found := slices.BinarySearch(bits, b)
+	if found {
+		return bits
+	}
+	return slices.Insert(bits, i, b)
+}
+
+func (idx *scopeIndex) match(t target, entries []scopeEntry) bool {
+	found := false
+	idx.each(t, entries, func(int) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+func (idx *scopeIndex) each(t target, entries []scopeEntry, fn func(i int) bool) {
+	if t.host == "" {
+		return
+	}
+	if !eachAccepted(idx.any, t, entries, fn) {
+		return
+	}
+	if !idx.eachHost(t, entries, fn) {
+		return
+	}
+	for _, i := range idx.regexes {
+		e := &entries[i]
+		if e.accepts(t) && e.info().re.MatchString(t.host) {
+			if !fn(i) {
+				return
+			}
+		}
+	}
+}
+
+func (idx *scopeIndex) eachHost(t target, entries []scopeEntry, fn func(i int) bool) bool {
+	host := t.host
+	if ip := net.ParseIP(host); ip != nil {
+		var one [1]int
+		if !eachAccepted(idx.names.exact(ip.String(), &one), t, entries, fn) {
+			return false
+		}
+		return idx.eachCIDR(ip, t, entries, fn)
+	}
+	if !idx.names.each(host, func(ids []int) bool { return eachAccepted(ids, t, entries, fn) }) {
+		return false
+	}
+	for _, i := range idx.tlds {
+		e := &entries[i]
+		if e.accepts(t) && e.matchTLDWildcard(host) {
+			if !fn(i) {
+				return false
+			}
+		}
+	}
+	for _, ids := range [][]int{idx.patterns[strings.Count(host, ".")+1], idx.deep} {
+		for _, i := range ids {
+			e := &entries[i]
+			if e.accepts(t) && matchPatternWildcard(host, e.patternLabels) {
+				if !fn(i) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (idx *scopeIndex) eachCIDR(ip net.IP, t target, entries []scopeEntry, fn func(i int) bool) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return true
+	}
+	addr = addr.Unmap()
+	bits := idx.bits6
+	if addr.Is4() {
+		bits = idx.bits4
+	}
+	for _, b := range bits {
+		p, err := addr.Prefix(b)
+		if err != nil {
+			continue
+		}
+		if !eachAccepted(idx.cidrs[p], t, entries, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func eachAccepted(ids []int, t target, entries []scopeEntry, fn func(i int) bool) bool {
+	for _, i := range ids {
+		if entries[i].accepts(t) {
+			if !fn(i) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package nscope
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// BenchmarkExclusions500k matches against a scope of * with 500k exclusions
+// of specific hosts and addresses, which must stay lookups rather than scans.
+// The target is well above 1M lines per minute (about 17k lines/s).
+func BenchmarkExclusions500k(b *testing.B) {
+	var scope bytes.Buffer
+	scope.WriteString("*\n")
+	for i := range 500_000 {
+		if i%5 == 0 {
+			fmt.Fprintf(&scope, "!10.%d.%d.%d\n", i>>16&255, i>>8&255, i&255)
+		} else {
+			fmt.Fprintf(&scope, "!host%d.example.com\n", i)
+		}
+	}
+	m, err := ParseScope(&scope)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var input bytes.Buffer
+	const lines = 10_000
+	for i := range lines {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&input, "https://host%d.example.com/login\n", i*37)
+		case 1:
+			fmt.Fprintf(&input, "https://app%d.example.com/\n", i)
+		case 2:
+			fmt.Fprintf(&input, "10.%d.%d.%d:443\n", i>>16&255, i>>8&255, i&255)
+		default:
+			fmt.Fprintf(&input, "other%d.example.org\n", i)
+		}
+	}
+	opts := Options{MaxHostLength: DefaultMaxHostLength}
+	b.ResetTimer()
+	for range b.N {
+		st, err := ProcessLines(bytes.NewReader(input.Bytes()), io.Discard, m, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if st.Excluded != lines/4 || st.Included != lines-st.Excluded {
+			b.Fatalf("got %d excluded and %d included lines", st.Excluded, st.Included)
+		}
+	}
+	b.ReportMetric(float64(lines*b.N)/b.Elapsed().Seconds(), "lines/s")
+}
+
+func TestLabelTrie(t *testing.T) {
+	tr := newLabelTrie()
+	tr.add("example.com", 0, true)
+	tr.add("api.example.com", 1, false)
+	tr.add("example.com", 2, true)
+	tr.add("api.example.com", 3, false)
+	tr.add("com", 4, true)
+	tr.add("10.0.0.1", 5, false)
+
+	var got [][]int
+	tr.each("api.example.com", func(ids []int) bool {
+		got = append(got, append([]int(nil), ids...))
+		return true
+	})
+	want := "[[4] [0 2] [] [1 3]]"
+	if s := fmt.Sprint(got); s != want {
+		t.Errorf("each(api.example.com) = %s, want %s", s, want)
+	}
+	var one [1]int
+	if ids := tr.exact("10.0.0.1", &one); fmt.Sprint(ids) != "[5]" {
+		t.Errorf("exact(10.0.0.1) = %v, want [5]", ids)
+	}
+	if ids := tr.exact("0.0.1", &one); ids != nil {
+		t.Errorf("exact(0.0.1) = %v, want none", ids)
+	}
+}
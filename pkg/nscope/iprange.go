@@ -0,0 +1,69 @@
+package nscope
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+func parseRangeEntry(line string) (scopeEntry, bool) {
+	lo, hi, ok := strings.Cut(line, "-")
+	if !ok {
+		return scopeEntry{}, false
+	}
+	start, err := netip.ParseAddr(strings.TrimSpace(lo))
+	if err != nil {
+		return scopeEntry{}, false
+	}
+	start = start.Unmap()
+	hi = strings.TrimSpace(hi)
+	var end netip.Addr
+	if n, err := strconv.Atoi(hi); err == nil && start.Is4() {
+		if n < 0 || n > 255 {
+			return scopeEntry{}, false
+		}
+		b := start.As4()
+		b[3] = byte(n)
+		end = netip.AddrFrom4(b)
+	} else if end, err = netip.ParseAddr(hi); err != nil {
+		return scopeEntry{}, false
+	}
+	end = end.Unmap()
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return scopeEntry{}, false
+	}
+	return scopeEntry{
+		kind: scopeRange,
+		base: start.String() + "-" + end.String(),
+		meta: &entryMeta{prefixes: rangePrefixes(start, end)},
+	}, true
+}
+
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for {
+		var p netip.Prefix
+		for bits := 0; bits <= start.BitLen(); bits++ {
+			cand := netip.PrefixFrom(start, bits).Masked()
+			if cand.Addr() == start && !end.Less(lastAddr(cand)) {
+				p = cand
+				break
+			}
+		}
+		out = append(out, p)
+		last := lastAddr(p)
+		if last == end {
+			return out
+		}
+		start = last.Next()
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
@@ -0,0 +1,135 @@
+package nscope
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+)
+
+func jsonField(line, path string) (string, bool) {
+	var v any
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if key == "" {
+			continue
+		}
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// jsonIP returns the address in the "ip" field of a JSON record, or else the
+// first in its "a" list, as httpx writes them.
+func jsonIP(line string) (string, bool) {
+	var rec struct {
+		IP string   `json:"ip"`
+		A  []string `json:"a"`
+	}
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return "", false
+	}
+	for _, s := range append([]string{rec.IP}, rec.A...) {
+		if ip := net.ParseIP(s); ip != nil {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
+
+type httpxRecord struct {
+	URL   string          `json:"url"`
+	Input string          `json:"input"`
+	Host  string          `json:"host"`
+	Port  json.RawMessage `json:"port"`
+	A     []string        `json:"a"`
+	AAAA  []string        `json:"aaaa"`
+}
+
+func prepareHTTPXLine(res *Result, input string, opts Options, st *Stats) bool {
+	var rec httpxRecord
+	if err := json.Unmarshal([]byte(input), &rec); err != nil {
+		return rejectLine(res, st, RejectRecord)
+	}
+	target := rec.URL
+	if target == "" {
+		target = rec.Input
+	}
+	ex, ok := extractHostFromLine(target)
+	if !ok {
+		return rejectLine(res, st, RejectNoHost)
+	}
+	if !withinHostLimits(ex.host, opts.MaxHostLength) {
+		return rejectLine(res, st, RejectTooLong)
+	}
+	if ex.badURL {
+		return rejectLine(res, st, RejectBadURL)
+	}
+	h, err := normalizeHost(ex.host)
+	if err != nil || h == "" {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	if ex.port == "" {
+		ex.port = strings.Trim(string(rec.Port), `"`)
+	}
+	st.Parsed++
+	res.Host, res.Port, res.Scheme, res.Path = h, ex.port, ex.scheme, ex.path
+	ips := append(append([]string{}, rec.A...), rec.AAAA...)
+	if net.ParseIP(rec.Host) != nil {
+		ips = append(ips, rec.Host)
+	}
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.String() != h {
+			res.fallbackIPs = append(res.fallbackIPs, parsed.String())
+		}
+	}
+	return true
+}
+
+type amassRecord struct {
+	Name      string `json:"name"`
+	Addresses []struct {
+		IP string `json:"ip"`
+	} `json:"addresses"`
+}
+
+func prepareAmassLine(res *Result, input string, opts Options, st *Stats) bool {
+	var rec amassRecord
+	if err := json.Unmarshal([]byte(input), &rec); err != nil {
+		return rejectLine(res, st, RejectRecord)
+	}
+	if !withinHostLimits(rec.Name, opts.MaxHostLength) {
+		return rejectLine(res, st, RejectTooLong)
+	}
+	h, err := normalizeHost(rec.Name)
+	if err != nil || h == "" {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	st.Parsed++
+	res.Host = h
+	for _, a := range rec.Addresses {
+		if ip := net.ParseIP(a.IP); ip != nil {
+			res.fallbackIPs = append(res.fallbackIPs, ip.String())
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package nscope
+
+import "testing"
+
+func TestHTTPXInput(t *testing.T) {
+	got, st := processFixture(t, "httpx.jsonl", "*.example.com\n10.0.0.0/8\n", Options{Input: "httpx"}, ProcessLines)
+	checkFixture(t, got, st, []string{
+		`{"url":"https://app.example.com","input":"app.example.com","host":"203.0.113.7","port":"443","a":["203.0.113.7"]}`,
+		`{"url":"http://cdn.other.net","input":"cdn.other.net","a":["10.1.2.3"]}`,
+		`{"input":"legacy.example.com"}`,
+	}, map[string]int{RejectRecord: 1, RejectNoHost: 1})
+	if st.Included != 3 || st.Unmatched != 1 {
+		t.Errorf("got %d included and %d unmatched records, want 3 and 1", st.Included, st.Unmatched)
+	}
+}
+
+func TestAmassInput(t *testing.T) {
+	got, st := processFixture(t, "amass.jsonl", "*.example.com\n10.0.0.0/8\n", Options{Input: "amass"}, ProcessLines)
+	checkFixture(t, got, st, []string{
+		`{"name":"app.example.com","domain":"example.com","addresses":[{"ip":"203.0.113.7","cidr":"203.0.113.0/24","asn":64500,"desc":"EXAMPLE"}],"tag":"dns","sources":["DNS"]}`,
+		`{"name":"vpn.other.net","domain":"other.net","addresses":[{"ip":"192.0.2.1","cidr":"192.0.2.0/24","asn":64501,"desc":"OTHER"},{"ip":"10.1.2.3","cidr":"10.0.0.0/8","asn":64502,"desc":"PRIVATE"}],"tag":"cert","sources":["crtsh"]}`,
+	}, map[string]int{RejectRecord: 1, RejectBadHost: 1})
+	if st.Included != 2 || st.Unmatched != 1 {
+		t.Errorf("got %d included and %d unmatched records, want 2 and 1", st.Included, st.Unmatched)
+	}
+}
@@ -0,0 +1,193 @@
+package nscope
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Checks reported by a Linter.
+const (
+	LintMalformed    = "malformed"
+	LintPort         = "port"
+	LintIDNA         = "idna"
+	LintPublicSuffix = "public-suffix"
+	LintDuplicate    = "duplicate"
+	LintShadowed     = "shadowed"
+)
+
+// LintIssue is a problem with one scope entry.
+type LintIssue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Entry   string `json:"entry"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+func (is LintIssue) String() string {
+	loc := fmt.Sprintf("line %d", is.Line)
+	if is.File != "" {
+		loc = fmt.Sprintf("%s:%d", is.File, is.Line)
+	}
+	return fmt.Sprintf("%s: %s: %q %s", loc, is.Check, is.Entry, is.Message)
+}
+
+// A Linter looks for scope entries that are probably mistakes: entries that
+// do not parse or are not valid names, invalid ports, IDNA failures, entries
+// covering a whole public suffix, duplicates, and entries that can never
+// decide a match because another entry covers them.
+type Linter struct {
+	files   []string
+	entries []scopeEntry
+	issues  []LintIssue
+}
+
+// ReadScope adds the entries of a scope file to l. Every line of a text scope
+// is checked on its own, so one malformed entry does not hide the others;
+// other formats must load to be checked.
+func (l *Linter) ReadScope(r io.Reader, name string) error {
+	l.files = append(l.files, name)
+	br := bufio.NewReader(r)
+	lo := LoadOptions{KeepASNs: true}
+	if !isTextScope(br, name) {
+		m, err := readScope(br, name, lo)
+		if err != nil {
+			return err
+		}
+		for _, e := range m.entries {
+			l.add(e)
+		}
+		return nil
+	}
+	return scanScopeText(br, func(lineNo int, rule, comment string) error {
+		ent, err := lo.parseEntry(rule, name, lineNo)
+		if err != nil {
+			check := LintMalformed
+			if errors.Is(err, errInvalidPort) {
+				check = LintPort
+			}
+			_, msg, _ := strings.Cut(err.Error(), fmt.Sprintf("%s:%d: ", name, lineNo))
+			l.issues = append(l.issues, LintIssue{File: name, Line: lineNo, Entry: rule, Check: check, Message: msg})
+			return nil
+		}
+		l.add(ent)
+		return nil
+	})
+}
+
+func (l *Linter) add(e scopeEntry) {
+	l.entries = append(l.entries, e)
+	report := func(check, format string, args ...any) {
+		l.issues = append(l.issues, LintIssue{File: e.file, Line: e.line, Entry: e.label(), Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+	if e.idnaFailed {
+		report(LintIDNA, "fails IDNA conversion")
+	} else if msg := e.nameProblem(); msg != "" {
+		report(LintMalformed, "%s", msg)
+	}
+	if slices.ContainsFunc(e.ports, func(r portRange) bool { return r.lo == 0 }) {
+		report(LintPort, "includes port 0")
+	}
+	if e.coversPublicSuffix() {
+		report(LintPublicSuffix, "covers the public suffix %s", e.base)
+	}
+}
+
+// nameProblem describes why the name of an exact or wildcard entry cannot be
+// a host name, or returns "".
+func (e *scopeEntry) nameProblem() string {
+	var labels []string
+	switch e.kind {
+	case scopeExact:
+		if isIPHost(e.base) {
+			return ""
+		}
+		labels = strings.Split(e.base, ".")
+	case scopeLeadingWildcard:
+		labels = strings.Split(e.base, ".")
+	case scopeTLDWildcard:
+		labels = strings.Split(strings.TrimPrefix(e.base, "*."), ".")
+	case scopePatternWildcard:
+		labels = e.patternLabels
+	default:
+		return ""
+	}
+	if len(strings.Join(labels, ".")) > 253 {
+		return "is longer than 253 characters"
+	}
+	for _, lbl := range labels {
+		switch {
+		case lbl == "":
+			return "has an empty label"
+		case lbl == "*" && e.kind == scopePatternWildcard:
+		case len(lbl) > 63:
+			return "has a label longer than 63 characters"
+		case strings.IndexFunc(lbl, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '*' && e.kind == scopePatternWildcard)
+		}) != -1:
+			return fmt.Sprintf("has an invalid label %q", lbl)
+		}
+	}
+	return ""
+}
+
+// Issues returns the problems found in the scopes read so far, ordered by
+// file, in the order they were read, and line.
+func (l *Linter) Issues() []LintIssue {
+	issues := slices.Clone(l.issues)
+	seen := make(map[string]int)
+	indexes := [2]*coverIndex{newCoverIndex(l.entries, false), newCoverIndex(l.entries, true)}
+	for j := range l.entries {
+		o := &l.entries[j]
+		report := func(check, format string, args ...any) {
+			issues = append(issues, LintIssue{File: o.file, Line: o.line, Entry: o.label(), Check: check, Message: fmt.Sprintf(format, args...)})
+		}
+		key := o.canonical()
+		if i, ok := seen[key]; ok {
+			report(LintDuplicate, "repeats the entry at %s", l.entries[i].location())
+			continue
+		}
+		seen[key] = j
+		if o.kind == scopeASN && len(o.info().prefixes) == 0 {
+			continue
+		}
+		polarity := 0
+		if o.exclude {
+			polarity = 1
+		}
+		if i := indexes[polarity].covering(j); i >= 0 {
+			report(LintShadowed, "is covered by %q at %s", l.entries[i].label(), l.entries[i].location())
+		} else if !o.exclude {
+			if i := indexes[1].covering(j); i >= 0 {
+				report(LintShadowed, "never matches: excluded by %q at %s", l.entries[i].label(), l.entries[i].location())
+			}
+		}
+	}
+	rank := func(file string) int {
+		if i := slices.Index(l.files, file); i >= 0 {
+			return i
+		}
+		return len(l.files)
+	}
+	slices.SortStableFunc(issues, func(a, b LintIssue) int {
+		return cmp.Or(cmp.Compare(rank(a.File), rank(b.File)), cmp.Compare(a.Line, b.Line))
+	})
+	return issues
+}
+
+// isTextScope reports whether readScope would read br as a text scope.
+func isTextScope(br *bufio.Reader, path string) bool {
+	if b, _ := br.Peek(len(compiledMagic)); string(b) == compiledMagic {
+		return false
+	}
+	switch documentStart(br) {
+	case '<', '{':
+		return false
+	}
+	return !isYAMLScope(br, path)
+}
@@ -0,0 +1,43 @@
+package nscope
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port   int    `json:"port"`
+		Proto  string `json:"proto"`
+		Status string `json:"status"`
+	} `json:"ports"`
+}
+
+func prepareMasscanLine(res *Result, input string, st *Stats) bool {
+	input = strings.TrimSpace(input)
+	var ip, port string
+	if strings.HasPrefix(input, "{") {
+		var rec masscanRecord
+		if err := json.Unmarshal([]byte(strings.TrimSuffix(input, ",")), &rec); err != nil || len(rec.Ports) == 0 {
+			return rejectLine(res, st, RejectRecord)
+		}
+		ip, port = rec.IP, strconv.Itoa(rec.Ports[0].Port)
+	} else {
+		f := strings.Fields(input)
+		if len(f) < 4 || f[0] != "open" {
+			return skipLine(res, st, RejectIgnored)
+		}
+		ip, port = f[3], f[2]
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	st.Parsed++
+	res.Host, res.Port = addr.String(), port
+	res.Line = net.JoinHostPort(res.Host, port)
+	return true
+}
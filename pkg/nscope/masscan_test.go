@@ -0,0 +1,23 @@
+package nscope
+
+import "testing"
+
+func TestMasscanInput(t *testing.T) {
+	const scope = "10.0.0.0/8\n203.0.113.7:443\n"
+	tests := []struct {
+		file    string
+		rejects map[string]int
+	}{
+		{"masscan.json", map[string]int{RejectIgnored: 2, RejectRecord: 1, RejectBadHost: 1}},
+		{"masscan.list", map[string]int{RejectComment: 2, RejectIgnored: 1, RejectBadHost: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, st := processFixture(t, tt.file, scope, Options{Input: "masscan"}, ProcessLines)
+			checkFixture(t, got, st, []string{"10.1.2.3:443", "203.0.113.7:443"}, tt.rejects)
+			if st.Included != 2 || st.Unmatched != 2 {
+				t.Errorf("got %d included and %d unmatched records, want 2 and 2", st.Included, st.Unmatched)
+			}
+		})
+	}
+}
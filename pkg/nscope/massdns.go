@@ -0,0 +1,32 @@
+package nscope
+
+import (
+	"net"
+	"strings"
+)
+
+// prepareMassdnsLine parses a massdns simple-output record such as
+// "www.example.com. A 192.0.2.1". The queried name is the host; an A or AAAA
+// answer is kept as a fallback so the record also matches on its address.
+func prepareMassdnsLine(res *Result, input string, opts Options, st *Stats) bool {
+	f := strings.Fields(input)
+	if len(f) < 3 {
+		return rejectLine(res, st, RejectRecord)
+	}
+	name := strings.TrimSuffix(f[0], ".")
+	if !withinHostLimits(name, opts.MaxHostLength) {
+		return rejectLine(res, st, RejectTooLong)
+	}
+	h, err := normalizeHost(name)
+	if err != nil || h == "" {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	st.Parsed++
+	res.Host = h
+	if t := strings.ToUpper(f[1]); t == "A" || t == "AAAA" {
+		if ip := net.ParseIP(f[2]); ip != nil {
+			res.fallbackIPs = append(res.fallbackIPs, ip.String())
+		}
+	}
+	return true
+}
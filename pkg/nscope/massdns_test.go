@@ -0,0 +1,15 @@
+package nscope
+
+import "testing"
+
+func TestMassdnsInput(t *testing.T) {
+	got, st := processFixture(t, "massdns.txt", "*.example.com\n10.0.0.0/8\n2001:db8::/32\n", Options{Input: "massdns"}, ProcessLines)
+	checkFixture(t, got, st, []string{
+		"app.example.com. A 203.0.113.7",
+		"edge.other.net. A 10.1.2.3",
+		"v6.other.org. AAAA 2001:db8::1",
+	}, map[string]int{RejectRecord: 1, RejectBadHost: 1})
+	if st.Included != 3 || st.Unmatched != 2 {
+		t.Errorf("got %d included and %d unmatched records, want 3 and 2", st.Included, st.Unmatched)
+	}
+}
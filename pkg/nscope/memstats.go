@@ -0,0 +1,137 @@
+package nscope
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// scopePacker copies the strings of compacted entries out of the lines they
+// were parsed from, so that the lines can be freed, and shares the copies
+// between entries: a host that ends an earlier one reuses its bytes.
+type scopePacker struct {
+	strs    map[string]string
+	arena   []byte
+	labels  []string
+	keepRaw bool
+}
+
+func newScopePacker(keepRaw bool) *scopePacker {
+	return &scopePacker{strs: make(map[string]string), keepRaw: keepRaw}
+}
+
+func (p *scopePacker) intern(s string) string {
+	if s == "" {
+		return ""
+	}
+	if v, ok := p.strs[s]; ok {
+		return v
+	}
+	if cap(p.arena)-len(p.arena) < len(s) {
+		p.arena = make([]byte, 0, max(64<<10, len(s)))
+	}
+	start := len(p.arena)
+	p.arena = append(p.arena, s...)
+	v := unsafe.String(&p.arena[start], len(s))
+	p.strs[v] = v
+	for rest := v; ; {
+		i := strings.IndexByte(rest, '.')
+		if i < 0 {
+			break
+		}
+		rest = rest[i+1:]
+		if _, ok := p.strs[rest]; ok || rest == "" {
+			break
+		}
+		p.strs[rest] = rest
+	}
+	return v
+}
+
+func (p *scopePacker) pack(e *scopeEntry) {
+	if p.keepRaw {
+		e.raw = p.intern(e.raw)
+	} else {
+		e.raw = ""
+	}
+	e.base = p.intern(e.base)
+	e.port = p.intern(e.port)
+	e.scheme = p.intern(e.scheme)
+	e.path = p.intern(e.path)
+	e.file = p.intern(e.file)
+	if e.meta != nil {
+		for i, t := range e.meta.tags {
+			e.meta.tags[i] = p.intern(t)
+		}
+	}
+	if len(e.patternLabels) == 0 {
+		return
+	}
+	if cap(p.labels)-len(p.labels) < len(e.patternLabels) {
+		p.labels = make([]string, 0, max(4096, len(e.patternLabels)))
+	}
+	start := len(p.labels)
+	for _, l := range e.patternLabels {
+		p.labels = append(p.labels, p.intern(l))
+	}
+	e.patternLabels = p.labels[start:len(p.labels):len(p.labels)]
+}
+
+type MemStats struct {
+	Entries int
+	ByKind  map[string]int
+	Bytes   int
+}
+
+func (m *Scope) MemStats() MemStats {
+	st := MemStats{Entries: len(m.entries), ByKind: make(map[string]int)}
+	seen := make(map[*byte]bool)
+	str := func(s string) int {
+		if s == "" {
+			return 0
+		}
+		p := unsafe.StringData(s)
+		if seen[p] {
+			return 0
+		}
+		seen[p] = true
+		return len(s)
+	}
+	labelArrays := make(map[*string]bool)
+	metas := make(map[*entryMeta]bool)
+	st.Bytes = cap(m.entries) * int(unsafe.Sizeof(scopeEntry{}))
+	for _, e := range m.entries {
+		st.ByKind[e.kind.String()]++
+		st.Bytes += str(e.raw) + str(e.base) + str(e.port) + str(e.scheme) + str(e.path) + str(e.file)
+		if e.meta != nil && !metas[e.meta] {
+			metas[e.meta] = true
+			st.Bytes += int(unsafe.Sizeof(entryMeta{})) + cap(e.meta.prefixes)*int(unsafe.Sizeof(netip.Prefix{}))
+		}
+		if len(e.patternLabels) == 0 {
+			continue
+		}
+		if p := unsafe.SliceData(e.patternLabels); !labelArrays[p] {
+			labelArrays[p] = true
+			st.Bytes += cap(e.patternLabels) * int(unsafe.Sizeof(""))
+		}
+		for _, l := range e.patternLabels {
+			st.Bytes += str(l)
+		}
+	}
+	return st
+}
+
+func (st MemStats) Kinds() string {
+	names := make([]string, 0, len(st.ByKind))
+	for k := range st.ByKind {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", k, st.ByKind[k]))
+	}
+	return strings.Join(parts, ", ")
+}
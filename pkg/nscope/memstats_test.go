@@ -0,0 +1,77 @@
+package nscope
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// syntheticScope returns a scope of n entries shaped like merged
+// certificate-transparency lists: mostly exact hosts under a few thousand
+// domains, with some wildcards and patterns.
+func syntheticScope(n int) []byte {
+	var buf bytes.Buffer
+	for i := range n {
+		domain := fmt.Sprintf("example%d.com", i%5000)
+		switch i % 10 {
+		case 0:
+			fmt.Fprintf(&buf, "*.svc%d.%s\n", i, domain)
+		case 1:
+			fmt.Fprintf(&buf, "api.*.env%d.%s\n", i, domain)
+		default:
+			fmt.Fprintf(&buf, "host%d.%s\n", i, domain)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestCompactKeepRaw(t *testing.T) {
+	data := syntheticScope(100)
+	for _, keep := range []bool{false, true} {
+		m, err := ReadScope(bytes.NewReader(data), "scope.txt", LoadOptions{Compact: true, KeepRaw: keep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.entries[1].raw != ""; got != keep {
+			t.Errorf("KeepRaw %v: raw kept %v", keep, got)
+		}
+	}
+}
+
+// heapAfterLoad returns the live heap held by a scope read from data.
+func heapAfterLoad(t testing.TB, data []byte, lo LoadOptions) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	m, err := ReadScope(bytes.NewReader(data), "scope.txt", lo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(m)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func TestCompactReducesHeap(t *testing.T) {
+	data := syntheticScope(200_000)
+	plain := heapAfterLoad(t, data, LoadOptions{})
+	compact := heapAfterLoad(t, data, LoadOptions{Compact: true})
+	t.Logf("heap: %d bytes, %d compacted", plain, compact)
+	if compact > plain*90/100 {
+		t.Errorf("compacted scope holds %d bytes of heap, want at least 10%% less than %d", compact, plain)
+	}
+}
+
+func BenchmarkLoadScope1M(b *testing.B) {
+	data := syntheticScope(1_000_000)
+	for _, compact := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compact=%v", compact), func(b *testing.B) {
+			for range b.N {
+				heap := heapAfterLoad(b, data, LoadOptions{Compact: compact})
+				b.ReportMetric(float64(heap)/(1<<20), "heap-MB")
+			}
+		})
+	}
+}
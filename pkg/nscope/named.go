@@ -0,0 +1,52 @@
+package nscope
+
+// NamedScope is one of several scopes that every line is matched against in
+// a single pass; see Options.Scopes.
+type NamedScope struct {
+	Name  string
+	Scope *Scope
+}
+
+// classifyNamed matches res against each of opts.Scopes. The line is
+// included when any scope includes it, and takes the rule of the first one
+// that does; otherwise it is excluded when any scope excludes it.
+// res.Scopes names the scopes that include it and res.ScopeVerdicts holds
+// the verdict of each scope.
+func classifyNamed(res *Result, opts Options) {
+	verdicts := make([]Verdict, len(opts.Scopes))
+	var names []string
+	var decided *Result
+	for i, ns := range opts.Scopes {
+		sub := *res
+		ns.Scope.Classify(&sub, opts)
+		verdicts[i] = sub.Verdict
+		switch {
+		case sub.Verdict == Included:
+			names = append(names, ns.Name)
+			if decided == nil || decided.Verdict != Included {
+				decided = &sub
+			}
+		case sub.Verdict == Excluded && (decided == nil || decided.Verdict == Unmatched):
+			decided = &sub
+		case decided == nil:
+			decided = &sub
+		}
+	}
+	if decided != nil {
+		*res = *decided
+	}
+	res.Scopes, res.ScopeVerdicts = names, verdicts
+}
+
+// countScopes adds the verdict of each named scope to st.ByScope.
+func (st *Stats) countScopes(verdicts []Verdict) {
+	if len(verdicts) == 0 {
+		return
+	}
+	if st.ByScope == nil {
+		st.ByScope = make([]Stats, len(verdicts))
+	}
+	for i, v := range verdicts {
+		st.ByScope[i].Count(v)
+	}
+}
@@ -0,0 +1,104 @@
+package nscope
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+)
+
+type nmapHost struct {
+	Addresses []struct {
+		Addr string `xml:"addr,attr"`
+		Type string `xml:"addrtype,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"hostnames>hostname"`
+	Ports []struct {
+		Protocol string `xml:"protocol,attr"`
+		ID       string `xml:"portid,attr"`
+		State    struct {
+			State string `xml:"state,attr"`
+		} `xml:"state"`
+	} `xml:"ports>port"`
+}
+
+func (h *nmapHost) names() []string {
+	var names []string
+	for _, hn := range h.Hostnames {
+		if n, err := normalizeHost(hn.Name); err == nil && n != "" {
+			names = append(names, n)
+		}
+	}
+	for _, a := range h.Addresses {
+		if ip := net.ParseIP(a.Addr); ip != nil {
+			names = append(names, ip.String())
+		}
+	}
+	return names
+}
+
+func ProcessNmap(r io.Reader, w io.Writer, scope *Scope, opts Options, servicesOnly bool) (Stats, error) {
+	var st Stats
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return st, err
+	}
+	out := w
+	if servicesOnly {
+		out = io.Discard
+	}
+	err = filterXML(doc, out, "host", func(dec *xml.Decoder, se *xml.StartElement) (bool, error) {
+		var h nmapHost
+		if err := dec.DecodeElement(&h, se); err != nil {
+			return false, err
+		}
+		st.Lines++
+		names := h.names()
+		if len(names) == 0 {
+			st.Invalid++
+			st.countReject(RejectNoHost)
+			return false, nil
+		}
+		st.Parsed++
+		verdict := Unmatched
+		var services []string
+		check := func(port string) {
+			v, decided := scope.alternateVerdict(target{port: port}, names, opts)
+			switch {
+			case v == Included:
+				verdict = Included
+			case v == Excluded && verdict == Unmatched:
+				verdict = Excluded
+			}
+			if port != "" && (v == Included) != opts.Reverse {
+				host := decided.host
+				if v == Unmatched {
+					host = names[0]
+				}
+				services = append(services, net.JoinHostPort(host, port))
+			}
+		}
+		open := 0
+		for _, p := range h.Ports {
+			if p.State.State == "open" {
+				open++
+				check(p.ID)
+			}
+		}
+		if open == 0 {
+			check("")
+		}
+		st.Count(verdict)
+		if servicesOnly {
+			for _, svc := range services {
+				if _, err := fmt.Fprintln(w, svc); err != nil {
+					return false, err
+				}
+			}
+		}
+		return (verdict == Included) != opts.Reverse, nil
+	})
+	return st, err
+}
@@ -0,0 +1,37 @@
+package nscope
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNmapInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+	process := func(servicesOnly bool) func(io.Reader, io.Writer, *Scope, Options) (Stats, error) {
+		return func(r io.Reader, w io.Writer, m *Scope, opts Options) (Stats, error) {
+			return ProcessNmap(r, w, m, opts, servicesOnly)
+		}
+	}
+
+	got, st := processFixture(t, "nmap.xml", scope, Options{Input: "nmap"}, process(false))
+	doc := strings.Join(got, "\n")
+	for _, addr := range []string{`"203.0.113.7"`, `"10.1.2.3"`, "<runstats>"} {
+		if !strings.Contains(doc, addr) {
+			t.Errorf("filtered document lacks %s", addr)
+		}
+	}
+	for _, addr := range []string{`"192.0.2.1"`, `"not-an-ip"`} {
+		if strings.Contains(doc, addr) {
+			t.Errorf("filtered document keeps %s", addr)
+		}
+	}
+	if st.Lines != 4 || st.Invalid != 1 || st.Rejects[RejectNoHost] != 1 || st.Included != 2 || st.Unmatched != 1 {
+		t.Errorf("got %d hosts, %d invalid, %d included, %d unmatched; want 4, 1, 2, 1", st.Lines, st.Invalid, st.Included, st.Unmatched)
+	}
+
+	got, _ = processFixture(t, "nmap.xml", scope, Options{Input: "nmap"}, process(true))
+	checkFixture(t, got, Stats{}, []string{"app.example.com:443"}, nil)
+	got, _ = processFixture(t, "nmap.xml", scope, Options{Input: "nmap", Reverse: true}, process(true))
+	checkFixture(t, got, Stats{}, []string{"other.org:80"}, nil)
+}
@@ -0,0 +1,121 @@
+package nscope
+
+import (
+	"io"
+	"sync"
+)
+
+const parallelBatchSize = 1024
+
+type lineBatch struct {
+	seq     int
+	results []Result
+	st      Stats
+}
+
+func scanParallel(r io.Reader, scope *Scope, opts Options, fn func(*Result) error) (Stats, error) {
+	jobs := make(chan *lineBatch, opts.Workers)
+	done := make(chan *lineBatch, opts.Workers)
+	slots := make(chan struct{}, 4*opts.Workers)
+	stop := make(chan struct{})
+	var readErr error
+
+	send := func(b *lineBatch) bool {
+		select {
+		case slots <- struct{}{}:
+		case <-stop:
+			return false
+		}
+		select {
+		case jobs <- b:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	go func() {
+		defer close(jobs)
+		scanner := NewLineScanner(r)
+		if opts.NulSeparated {
+			scanner.Split(ScanNulRecords)
+		}
+		lineNo, seq := 0, 0
+		b := &lineBatch{}
+		for scanner.Scan() {
+			lineNo++
+			b.results = append(b.results, Result{LineNo: lineNo, Line: scanner.Text()})
+			if len(b.results) == parallelBatchSize {
+				if !send(b) {
+					return
+				}
+				seq++
+				b = &lineBatch{seq: seq}
+			}
+		}
+		if len(b.results) > 0 && !send(b) {
+			return
+		}
+		readErr = scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				b.st.Lines = len(b.results)
+				m := currentScope(scope, opts)
+				for i := range b.results {
+					res := &b.results[i]
+					if PrepareLine(res, opts, &b.st) {
+						classify(m, res, opts, &b.st)
+					}
+				}
+				select {
+				case done <- b:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var st Stats
+	var err error
+	emit := func(b *lineBatch) {
+		<-slots
+		st.add(b.st)
+		for i := range b.results {
+			if err = fn(&b.results[i]); err != nil {
+				close(stop)
+				return
+			}
+		}
+	}
+	pending := make(map[int]*lineBatch)
+	next := 0
+	for b := range done {
+		if err != nil {
+			continue
+		}
+		if opts.Unordered {
+			emit(b)
+			continue
+		}
+		pending[b.seq] = b
+		for pb, ok := pending[next]; ok && err == nil; pb, ok = pending[next] {
+			delete(pending, next)
+			emit(pb)
+			next++
+		}
+	}
+	if err != nil {
+		return st, err
+	}
+	return st, readErr
+}
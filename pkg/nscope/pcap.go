@@ -0,0 +1,374 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ProcessPCAP extracts the hosts a capture talked to (DNS queries, TLS SNI,
+// HTTP Host headers and the destinations of TCP connection attempts and of
+// UDP datagrams sent to a service port) and filters them like ordinary input lines. Each host is
+// reported once, in the order it was first seen.
+func ProcessPCAP(r io.Reader, w io.Writer, scope *Scope, opts Options) (Stats, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	err := readPackets(bufio.NewReader(r), func(link uint32, data []byte) {
+		for _, h := range packetHosts(link, data) {
+			if !seen[h] {
+				seen[h] = true
+				hosts = append(hosts, h)
+			}
+		}
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("reading capture: %w", err)
+	}
+	opts.Input = ""
+	var buf bytes.Buffer
+	for _, h := range hosts {
+		buf.WriteString(h)
+		buf.WriteByte('\n')
+	}
+	return ProcessLines(&buf, w, scope, opts)
+}
+
+const (
+	pcapngSectionHeader   = 0x0a0d0d0a
+	pcapngInterface       = 1
+	pcapngSimplePacket    = 3
+	pcapngEnhancedPacket  = 6
+	pcapngByteOrderMagic  = 0x1a2b3c4d
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+)
+
+// readPackets calls fn with the link type and data of every packet in a
+// pcap or pcapng capture.
+func readPackets(r *bufio.Reader, fn func(link uint32, data []byte)) error {
+	magic, err := r.Peek(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(magic) == pcapngSectionHeader {
+		return readPcapng(r, fn)
+	}
+	return readPcap(r, fn)
+}
+
+func readPcap(r io.Reader, fn func(link uint32, data []byte)) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return err
+	}
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(hdr[:4]) {
+	case pcapMagicMicroseconds, pcapMagicNanoseconds:
+		order = binary.LittleEndian
+	default:
+		switch binary.BigEndian.Uint32(hdr[:4]) {
+		case pcapMagicMicroseconds, pcapMagicNanoseconds:
+			order = binary.BigEndian
+		default:
+			return errors.New("not a pcap or pcapng file")
+		}
+	}
+	link := order.Uint32(hdr[20:]) & 0xffff
+	var rec [16]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		n := order.Uint32(rec[8:])
+		if n > 1<<24 {
+			return fmt.Errorf("packet too large (%d bytes)", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		fn(link, data)
+	}
+}
+
+func readPcapng(r io.Reader, fn func(link uint32, data []byte)) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	var links []uint32
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		typ := order.Uint32(hdr[:4])
+		if typ == pcapngSectionHeader {
+			var bom [4]byte
+			if _, err := io.ReadFull(r, bom[:]); err != nil {
+				return err
+			}
+			if binary.BigEndian.Uint32(bom[:]) == pcapngByteOrderMagic {
+				order = binary.BigEndian
+			} else {
+				order = binary.LittleEndian
+			}
+			links = links[:0]
+			if err := skipBlock(r, order.Uint32(hdr[4:]), 12); err != nil {
+				return err
+			}
+			continue
+		}
+		size := order.Uint32(hdr[4:])
+		if size < 12 || size > 1<<24 {
+			return fmt.Errorf("invalid pcapng block length %d", size)
+		}
+		body := make([]byte, size-8)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		body = body[:len(body)-4]
+		switch typ {
+		case pcapngInterface:
+			if len(body) >= 2 {
+				links = append(links, uint32(order.Uint16(body)))
+			}
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				continue
+			}
+			id, n := order.Uint32(body), order.Uint32(body[12:])
+			if int(id) < len(links) && int(n) <= len(body)-20 {
+				fn(links[id], body[20:20+n])
+			}
+		case pcapngSimplePacket:
+			if len(body) >= 4 && len(links) > 0 {
+				n := min(int(order.Uint32(body)), len(body)-4)
+				fn(links[0], body[4:4+n])
+			}
+		}
+	}
+}
+
+func skipBlock(r io.Reader, size uint32, read int64) error {
+	if int64(size) < read || size > 1<<24 {
+		return fmt.Errorf("invalid pcapng block length %d", size)
+	}
+	_, err := io.CopyN(io.Discard, r, int64(size)-read)
+	return err
+}
+
+// packetHosts decodes a single captured frame down to its transport payload
+// and returns the hosts it reveals.
+func packetHosts(link uint32, data []byte) []string {
+	var proto uint16
+	switch link {
+	case 0: // BSD loopback
+		if len(data) < 4 {
+			return nil
+		}
+		data = data[4:]
+	case 1: // Ethernet
+		if len(data) < 14 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+		for (proto == 0x8100 || proto == 0x88a8) && len(data) >= 4 {
+			proto, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case 101, 228, 229: // raw IP
+	case 113: // Linux cooked
+		if len(data) < 16 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case 276: // Linux cooked v2
+		if len(data) < 20 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data), data[20:]
+	default:
+		return nil
+	}
+	if len(data) == 0 || (proto != 0 && proto != 0x0800 && proto != 0x86dd) {
+		return nil
+	}
+	var dst net.IP
+	var next byte
+	switch data[0] >> 4 {
+	case 4:
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < 20 || ihl < 20 || len(data) < ihl {
+			return nil
+		}
+		if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+			return nil
+		}
+		next, dst = data[9], net.IP(data[16:20])
+		data = data[ihl:]
+	case 6:
+		if len(data) < 40 {
+			return nil
+		}
+		next, dst = data[6], net.IP(data[24:40])
+		data = data[40:]
+	default:
+		return nil
+	}
+
+	var hosts []string
+	switch next {
+	case 6: // TCP
+		if len(data) < 20 {
+			return nil
+		}
+		port := binary.BigEndian.Uint16(data[2:])
+		off := int(data[12]>>4) * 4
+		if off < 20 || len(data) < off {
+			return nil
+		}
+		if data[13]&0x12 == 0x02 {
+			hosts = append(hosts, endpoint(dst.String(), port))
+		}
+		payload := data[off:]
+		if sni := clientHelloSNI(payload); sni != "" {
+			hosts = append(hosts, endpoint(sni, port))
+		} else if h := httpHost(payload); h != "" {
+			if _, _, err := net.SplitHostPort(h); err != nil {
+				h = endpoint(strings.Trim(h, "[]"), port)
+			}
+			hosts = append(hosts, h)
+		}
+	case 17: // UDP
+		if len(data) < 8 {
+			return nil
+		}
+		src, port := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if port < src {
+			hosts = append(hosts, endpoint(dst.String(), port))
+		}
+		if port == 53 || port == 5353 {
+			hosts = append(hosts, dnsQuestions(data[8:])...)
+		}
+	}
+	return hosts
+}
+
+func endpoint(host string, port uint16) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+func dnsQuestions(msg []byte) []string {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil || h.Response {
+		return nil
+	}
+	var names []string
+	for {
+		q, err := p.Question()
+		if err != nil {
+			return names
+		}
+		if name := strings.TrimSuffix(q.Name.String(), "."); name != "" {
+			names = append(names, name)
+		}
+	}
+}
+
+// clientHelloSNI returns the server name from a TLS ClientHello, if the
+// payload starts with one.
+func clientHelloSNI(b []byte) string {
+	if len(b) < 9 || b[0] != 0x16 || b[5] != 0x01 {
+		return ""
+	}
+	b = b[9:]
+	if len(b) < 34 {
+		return ""
+	}
+	b = b[34:]
+	for _, width := range []int{1, 2, 1} {
+		var ok bool
+		if b, ok = skipVector(b, width); !ok {
+			return ""
+		}
+	}
+	if len(b) < 2 {
+		return ""
+	}
+	ext := b[2:]
+	if n := int(binary.BigEndian.Uint16(b)); n < len(ext) {
+		ext = ext[:n]
+	}
+	for len(ext) >= 4 {
+		typ, n := binary.BigEndian.Uint16(ext), int(binary.BigEndian.Uint16(ext[2:]))
+		if len(ext) < 4+n {
+			return ""
+		}
+		body := ext[4 : 4+n]
+		ext = ext[4+n:]
+		if typ != 0 || len(body) < 5 || body[2] != 0 {
+			continue
+		}
+		l := int(binary.BigEndian.Uint16(body[3:]))
+		if len(body) < 5+l {
+			return ""
+		}
+		return string(body[5 : 5+l])
+	}
+	return ""
+}
+
+func skipVector(b []byte, width int) ([]byte, bool) {
+	if len(b) < width {
+		return nil, false
+	}
+	n := int(b[0])
+	if width == 2 {
+		n = int(binary.BigEndian.Uint16(b))
+	}
+	if len(b) < width+n {
+		return nil, false
+	}
+	return b[width+n:], true
+}
+
+// httpHost returns the Host header of an HTTP/1.x request at the start of
+// payload.
+func httpHost(payload []byte) string {
+	sp := bytes.IndexByte(payload, ' ')
+	if sp < 3 || sp > 10 {
+		return ""
+	}
+	for _, c := range payload[:sp] {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	if len(lines) < 2 || !bytes.Contains(lines[0], []byte(" HTTP/1.")) {
+		return ""
+	}
+	for _, l := range lines[1:] {
+		l = bytes.TrimRight(l, "\r")
+		if len(l) == 0 {
+			break
+		}
+		name, value, ok := bytes.Cut(l, []byte(":"))
+		if ok && strings.EqualFold(string(name), "host") {
+			return string(bytes.TrimSpace(value))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package nscope
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPCAPInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+	for _, file := range []string{"capture.pcap", "capture.pcapng"} {
+		t.Run(file, func(t *testing.T) {
+			got, st := processFixture(t, file, scope, Options{Input: "pcap"}, ProcessPCAP)
+			checkFixture(t, got, st, []string{"app.example.com", "10.1.2.3:443", "admin.example.com:443"}, map[string]int{})
+			if st.Lines != 5 {
+				t.Errorf("got %d hosts, want 5 (truncated frames skipped, repeats reported once)", st.Lines)
+			}
+			got, st = processFixture(t, file, scope, Options{Input: "pcap", Reverse: true}, ProcessPCAP)
+			checkFixture(t, got, st, []string{"192.168.1.1:53", "other.org:80"}, map[string]int{})
+		})
+	}
+
+	m, err := ParseScope(strings.NewReader(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessPCAP(strings.NewReader("GET / HTTP/1.1\r\nHost: app.example.com\r\n\r\n"), io.Discard, m, Options{}); err == nil {
+		t.Error("a text file was read as a capture")
+	}
+	b, err := os.ReadFile(filepath.Join("testdata", "capture.pcap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessPCAP(strings.NewReader(string(b[:len(b)-10])), io.Discard, m, Options{}); err == nil {
+		t.Error("a truncated capture was read without error")
+	}
+}
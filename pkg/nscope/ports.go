@@ -0,0 +1,126 @@
+package nscope
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errInvalidPort = errors.New("invalid port")
+
+type portRange struct {
+	lo, hi int
+}
+
+func parsePortSpec(spec string) ([]portRange, error) {
+	var out []portRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		l, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		h := l
+		if isRange {
+			if h, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if h < l {
+				return nil, fmt.Errorf("%w range %q", errInvalidPort, part)
+			}
+		}
+		out = append(out, portRange{l, h})
+	}
+	return out, nil
+}
+
+// formatPortSpec writes port ranges in a canonical form: sorted, with
+// overlapping and adjacent ranges merged.
+func formatPortSpec(ranges []portRange) string {
+	rs := slices.Clone(ranges)
+	slices.SortFunc(rs, func(a, b portRange) int { return a.lo - b.lo })
+	var merged []portRange
+	for _, r := range rs {
+		if n := len(merged); n > 0 && r.lo <= merged[n-1].hi+1 {
+			merged[n-1].hi = max(merged[n-1].hi, r.hi)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	parts := make([]string, len(merged))
+	for i, r := range merged {
+		parts[i] = strconv.Itoa(r.lo)
+		if r.hi != r.lo {
+			parts[i] += "-" + strconv.Itoa(r.hi)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 0 || p > 65535 {
+		return 0, fmt.Errorf("%w %q", errInvalidPort, s)
+	}
+	return p, nil
+}
+
+func (e *scopeEntry) accepts(t target) bool {
+	if e.noApex && e.kind == scopeLeadingWildcard && t.host == e.base {
+		return false
+	}
+	if e.scheme != "" && t.scheme != "" && e.scheme != t.scheme {
+		return false
+	}
+	if e.path != "" && !e.pathMatches(t.path) {
+		return false
+	}
+	return e.portMatches(t.port)
+}
+
+func (e *scopeEntry) pathMatches(path string) bool {
+	prefix, ok := strings.CutSuffix(e.path, "*")
+	if !ok {
+		return path == e.path
+	}
+	return strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/")
+}
+
+func (e *scopeEntry) portMatches(port string) bool {
+	if e.port == "" {
+		return true
+	}
+	if port == e.port {
+		return true
+	}
+	if len(e.ports) == 0 || port == "" {
+		return false
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, r := range e.ports {
+		if r.lo <= p && p <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+	"ftps":  "990",
+	"ssh":   "22",
+	"sftp":  "22",
+	"smtp":  "25",
+	"ldap":  "389",
+	"ldaps": "636",
+}
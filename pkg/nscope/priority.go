@@ -0,0 +1,84 @@
+package nscope
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type RulePriority int
+
+const (
+	PriorityFirst RulePriority = iota
+	PrioritySpecific
+)
+
+func ParseRulePriority(s string) (RulePriority, error) {
+	switch s {
+	case "first":
+		return PriorityFirst, nil
+	case "specific":
+		return PrioritySpecific, nil
+	}
+	return 0, fmt.Errorf("unknown rule priority %q", s)
+}
+
+func (m *Scope) rule(t target) (int, bool) {
+	if i, ok := m.bestRule(m.excludes, t); ok {
+		return i, true
+	}
+	return m.bestRule(m.index, t)
+}
+
+func (m *Scope) bestRule(idx *scopeIndex, t target) (int, bool) {
+	best := -1
+	idx.each(t, m.entries, func(i int) bool {
+		if best == -1 || m.preferred(i, best) {
+			best = i
+		}
+		return true
+	})
+	return best, best != -1
+}
+
+func (m *Scope) preferred(a, b int) bool {
+	if m.priority == PrioritySpecific {
+		ea, eb := &m.entries[a], &m.entries[b]
+		if c := slices.Compare(specificity(ea), specificity(eb)); c != 0 {
+			return c > 0
+		}
+	}
+	return a < b
+}
+
+func specificity(e *scopeEntry) []int {
+	var kind, labels, path, port int
+	switch e.kind {
+	case scopeExact:
+		kind = 2
+	case scopePatternWildcard, scopeCIDR, scopeRange, scopeASN, scopeTLDWildcard:
+		kind = 1
+	}
+	switch {
+	case e.kind == scopePatternWildcard:
+		labels = len(e.patternLabels)
+	case e.kind == scopeCIDR:
+		labels = e.network.Bits()
+	case e.base != "":
+		labels = strings.Count(e.base, ".") + 1
+	}
+	if e.path != "" {
+		path = len(strings.TrimSuffix(e.path, "*"))
+	}
+	if e.port != "" {
+		port = 1
+	}
+	return []int{kind, labels, path, port}
+}
+
+func (m *Scope) hit(what string, t target) string {
+	if i, ok := m.rule(t); ok {
+		return what + "=" + m.entries[i].label()
+	}
+	return what
+}
@@ -0,0 +1,43 @@
+package nscope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRulePriority(t *testing.T) {
+	tests := []struct {
+		scope    string
+		priority RulePriority
+		want     string
+	}{
+		{"*.example.com\napi.*.example.com\napi.prod.example.com\n", PriorityFirst, "*.example.com"},
+		{"*.example.com\napi.*.example.com\napi.prod.example.com\n", PrioritySpecific, "api.prod.example.com"},
+		{"api.*.example.com\n*.example.com\napi.prod.example.com\n", PriorityFirst, "api.*.example.com"},
+		{"api.*.example.com\n*.example.com\napi.prod.example.com\n", PrioritySpecific, "api.prod.example.com"},
+		{"api.prod.example.com\n*.example.com\napi.*.example.com\n", PriorityFirst, "api.prod.example.com"},
+		{"*.example.com\napi.*.example.com\n", PrioritySpecific, "api.*.example.com"},
+	}
+	for _, tt := range tests {
+		scope, err := ParseScope(strings.NewReader(tt.scope))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scope.SetRulePriority(tt.priority)
+		name := strings.ReplaceAll(strings.TrimSpace(tt.scope), "\n", ",")
+
+		ok, rule := scope.Match("api.prod.example.com", "443")
+		if !ok || rule.Text != tt.want {
+			t.Errorf("%s (priority %d): Match = %v %q, want true %q", name, tt.priority, ok, rule.Text, tt.want)
+		}
+
+		res := Result{Host: "api.prod.example.com", Scheme: "https"}
+		scope.Classify(&res, Options{Rules: true, Annotate: true})
+		if res.Verdict != Included || res.Rule.Text != tt.want {
+			t.Errorf("%s (priority %d): Classify = %v %q, want included %q", name, tt.priority, res.Verdict, res.Rule.Text, tt.want)
+		}
+		if res.Rule.Index != rule.Index {
+			t.Errorf("%s (priority %d): Classify rule %d, Match rule %d", name, tt.priority, res.Rule.Index, rule.Index)
+		}
+	}
+}
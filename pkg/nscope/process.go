@@ -0,0 +1,426 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+type Result struct {
+	LineNo  int
+	Line    string
+	Host    string
+	Port    string
+	Scheme  string
+	Path    string
+	IP      string
+	Verdict Verdict
+	Skipped bool
+	Invalid bool
+	Reject  string
+	Hits    []string
+	Rule    Rule
+	Err     error
+	Matches []string
+	// Scopes names the Options.Scopes that include the line, and
+	// ScopeVerdicts holds the verdict of each of them.
+	Scopes        []string
+	ScopeVerdicts []Verdict
+
+	candidates  []extracted
+	fallbackIPs []string
+}
+
+func ProcessLines(r io.Reader, w io.Writer, scope *Scope, opts Options) (Stats, error) {
+	enc := opts.Encoder
+	if enc == nil {
+		enc = newPlainEncoder(opts)
+	}
+	enc.Start(w)
+	printed := 0
+	st, err := ScanLines(r, scope, opts, func(res *Result) error {
+		if res.Err != nil && opts.Warnings != nil {
+			fmt.Fprintf(opts.Warnings, "line %d: %v\n", res.LineNo, res.Err)
+		}
+		if res.Skipped || res.Invalid {
+			if opts.RejectsOut != nil {
+				fmt.Fprintf(opts.RejectsOut, "%d\t%s\t%s\n", res.LineNo, res.Reject, res.Line)
+			}
+			return nil
+		}
+		if (res.Verdict == Included) != opts.Reverse {
+			if err := enc.Encode(*res); err != nil {
+				return err
+			}
+			printed++
+		}
+		if res.Verdict == Included && opts.InScopeOut != nil {
+			fmt.Fprintln(opts.InScopeOut, res.Line)
+		}
+		if res.Verdict != Included && opts.OutOfScopeOut != nil {
+			fmt.Fprintln(opts.OutOfScopeOut, res.Line)
+		}
+		if res.Verdict == Excluded && opts.ExcludedOut != nil {
+			fmt.Fprintln(opts.ExcludedOut, res.Line)
+		}
+		if res.Verdict == Unmatched && opts.UnmatchedOut != nil {
+			fmt.Fprintln(opts.UnmatchedOut, res.Line)
+		}
+		if opts.Limit > 0 && printed >= opts.Limit {
+			return errLimitReached
+		}
+		return nil
+	})
+	if err == errLimitReached {
+		err = nil
+	}
+	if ferr := enc.Finish(); err == nil {
+		err = ferr
+	}
+	return st, err
+}
+
+// errLimitReached stops ScanLines once Options.Limit lines were printed.
+var errLimitReached = errors.New("limit reached")
+
+func ScanLines(r io.Reader, scope *Scope, opts Options, fn func(*Result) error) (Stats, error) {
+	if opts.Workers > 1 {
+		return scanParallel(r, scope, opts, fn)
+	}
+	var st Stats
+	scanner := NewLineScanner(r)
+	if opts.NulSeparated {
+		scanner.Split(ScanNulRecords)
+	}
+	for scanner.Scan() {
+		st.Lines++
+		res := Result{LineNo: st.Lines, Line: scanner.Text()}
+		if PrepareLine(&res, opts, &st) {
+			classify(currentScope(scope, opts), &res, opts, &st)
+		}
+		if err := fn(&res); err != nil {
+			return st, err
+		}
+	}
+	return st, scanner.Err()
+}
+
+// currentScope returns the scope to match the next line against: the one
+// most recently stored in opts.LiveScope, if set, and m otherwise.
+func currentScope(m *Scope, opts Options) *Scope {
+	if opts.LiveScope != nil {
+		return opts.LiveScope.Load()
+	}
+	return m
+}
+
+// classify matches a prepared line against m, or against each of
+// opts.Scopes if set, and counts its verdict.
+func classify(m *Scope, res *Result, opts Options, st *Stats) {
+	if len(opts.Scopes) > 0 {
+		classifyNamed(res, opts)
+		st.countScopes(res.ScopeVerdicts)
+	} else {
+		m.Classify(res, opts)
+	}
+	st.Count(res.Verdict)
+}
+
+func NewLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	const maxCapacity = 16 * 1024 * 1024
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, maxCapacity)
+	return scanner
+}
+
+// ScanNulRecords is a bufio.SplitFunc for NUL-terminated records, as written
+// by find -print0.
+func ScanNulRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func PrepareLine(res *Result, opts Options, st *Stats) bool {
+	input := res.Line
+	if opts.PreProcess != nil {
+		var keep bool
+		if input, keep = opts.PreProcess(res.Line); !keep {
+			return skipLine(res, st, RejectFiltered)
+		}
+	}
+	if opts.Refang {
+		input = Refang(input)
+	}
+	if t := strings.TrimSpace(input); t == "" {
+		return skipLine(res, st, RejectEmpty)
+	} else if strings.HasPrefix(t, "#") {
+		return skipLine(res, st, RejectComment)
+	}
+	record := input
+	if opts.JSONField != "" {
+		f, ok := jsonField(input, opts.JSONField)
+		if !ok {
+			return rejectLine(res, st, RejectNoField)
+		}
+		input = f
+	}
+	if opts.Field > 0 {
+		f, ok := selectField(input, opts.Delimiter, opts.Field)
+		if !ok {
+			return rejectLine(res, st, RejectNoField)
+		}
+		input = f
+	}
+	switch {
+	case opts.Input == "httpx":
+		return prepareHTTPXLine(res, input, opts, st)
+	case opts.Input == "amass":
+		return prepareAmassLine(res, input, opts, st)
+	case opts.Input == "masscan":
+		return prepareMasscanLine(res, input, st)
+	case opts.Input == "massdns":
+		return prepareMassdnsLine(res, input, opts, st)
+	case opts.Input == "certstream":
+		return prepareCertstreamLine(res, input, opts, st)
+	case opts.Grep:
+		return prepareGrepLine(res, input, opts, st)
+	}
+	ex, ok := extractHostFromLine(input)
+	if !ok {
+		return rejectLine(res, st, RejectNoHost)
+	}
+	if !withinHostLimits(ex.host, opts.MaxHostLength) {
+		return rejectLine(res, st, RejectTooLong)
+	}
+	if ex.badURL {
+		return rejectLine(res, st, RejectBadURL)
+	}
+	if ex.fallback {
+		st.Fallback++
+	}
+	if opts.StrictIDNA {
+		if err := checkIDNA(ex.host); err != nil {
+			res.Err = err
+			st.IDNARejected++
+			return rejectLine(res, st, RejectIDNA)
+		}
+	}
+	normHost, err := normalizeHost(ex.host)
+	if err != nil || normHost == "" {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	st.Parsed++
+	res.Host, res.Port, res.Scheme, res.Path = normHost, ex.port, ex.scheme, ex.path
+	if opts.WithIP {
+		if opts.JSONField != "" {
+			res.IP, _ = jsonIP(record)
+		} else {
+			res.IP, _ = ipColumn(input)
+		}
+	}
+	return true
+}
+
+func selectField(line, delim string, n int) (string, bool) {
+	var fields []string
+	switch {
+	case delim == "":
+		fields = strings.Fields(line)
+	case utf8.RuneCountInString(delim) == 1:
+		r := csv.NewReader(strings.NewReader(line))
+		r.Comma, _ = utf8.DecodeRuneInString(delim)
+		r.LazyQuotes = true
+		r.FieldsPerRecord = -1
+		var err error
+		if fields, err = r.Read(); err != nil {
+			return "", false
+		}
+	default:
+		fields = strings.Split(line, delim)
+	}
+	if n > len(fields) {
+		return "", false
+	}
+	return strings.TrimSpace(fields[n-1]), true
+}
+
+func prepareGrepLine(res *Result, input string, opts Options, st *Stats) bool {
+	res.candidates = res.candidates[:0]
+	for _, ex := range extractAllHosts(input) {
+		if !withinHostLimits(ex.host, opts.MaxHostLength) {
+			continue
+		}
+		h, err := normalizeHost(ex.host)
+		if err != nil || h == "" {
+			continue
+		}
+		ex.host = h
+		res.candidates = append(res.candidates, ex)
+	}
+	if len(res.candidates) == 0 {
+		return rejectLine(res, st, RejectNoHost)
+	}
+	st.Parsed++
+	ex := res.candidates[0]
+	res.Host, res.Port, res.Scheme, res.Path = ex.host, ex.port, ex.scheme, ex.path
+	return true
+}
+
+func (m *Scope) Classify(res *Result, opts Options) {
+	if len(res.candidates) == 0 {
+		m.classifyHost(res, opts)
+		return
+	}
+	var first *Result
+	verdict := Unmatched
+	res.Matches = nil
+	for _, ex := range res.candidates {
+		sub := *res
+		sub.Host, sub.Port, sub.Scheme, sub.Path = ex.host, ex.port, ex.scheme, ex.path
+		m.classifyHost(&sub, opts)
+		switch sub.Verdict {
+		case Included:
+			res.Matches = append(res.Matches, sub.Host)
+			if verdict != Included {
+				verdict, first = Included, &sub
+			}
+		case Excluded:
+			if verdict == Unmatched {
+				verdict, first = Excluded, &sub
+			}
+		}
+	}
+	if first == nil {
+		m.classifyHost(res, opts)
+		return
+	}
+	matches := res.Matches
+	*res = *first
+	res.Matches = matches
+}
+
+func (m *Scope) classifyHost(res *Result, opts Options) {
+	res.Hits = nil
+	res.Rule = Rule{Index: -1}
+	t := target{host: res.Host, port: res.Port, scheme: res.Scheme, path: res.Path}
+	if t.port == "" {
+		t.port = defaultPorts[t.scheme]
+	}
+	if opts.TrackUsage {
+		m.markUsed(t)
+	}
+	verdict := m.verdict(t)
+	if verdict == Included && opts.Annotate {
+		res.Hits = append(res.Hits, m.hit("host", t))
+	}
+	decided := t
+	if res.IP != "" {
+		it := t
+		it.host = res.IP
+		if opts.TrackUsage {
+			m.markUsed(it)
+		}
+		ipVerdict := m.verdict(it)
+		if ipVerdict == Included && opts.Annotate {
+			res.Hits = append(res.Hits, m.hit("ip", it))
+		}
+		combined := combineVerdicts(verdict, ipVerdict, opts.MatchAll)
+		if combined != verdict || verdict == Unmatched {
+			decided = it
+		}
+		verdict = combined
+	}
+	if verdict == Unmatched && len(res.fallbackIPs) > 0 {
+		verdict, decided = m.alternateVerdict(t, res.fallbackIPs, opts)
+		if verdict == Included && opts.Annotate {
+			res.Hits = append(res.Hits, m.hit("ip", decided))
+		}
+	}
+	if verdict == Unmatched && opts.Resolver != nil {
+		var hosts []string
+		label := "resolved"
+		if ip := net.ParseIP(t.host); ip == nil && opts.Resolve {
+			ips, _ := opts.Resolver.LookupIP(context.Background(), t.host)
+			for _, ip := range ips {
+				hosts = append(hosts, ip.String())
+			}
+		} else if ip != nil && opts.ReverseDNS {
+			names, _ := opts.Resolver.LookupAddr(context.Background(), t.host)
+			for _, n := range names {
+				if h, err := normalizeHost(n); err == nil && h != "" {
+					hosts = append(hosts, h)
+				}
+			}
+			label = "rdns"
+		}
+		verdict, decided = m.alternateVerdict(t, hosts, opts)
+		if verdict == Included && opts.Annotate {
+			res.Hits = append(res.Hits, m.hit(label, decided))
+		}
+	}
+	res.Verdict = verdict
+	if opts.Rules || opts.Explain {
+		if i, ok := m.rule(decided); ok {
+			res.Rule = m.ruleAt(i)
+		}
+	}
+}
+
+func (m *Scope) alternateVerdict(t target, hosts []string, opts Options) (Verdict, target) {
+	verdict, decided := Unmatched, t
+	for _, h := range hosts {
+		at := t
+		at.host = h
+		if opts.TrackUsage {
+			m.markUsed(at)
+		}
+		switch m.verdict(at) {
+		case Excluded:
+			return Excluded, at
+		case Included:
+			if verdict == Unmatched {
+				verdict, decided = Included, at
+			}
+		}
+	}
+	return verdict, decided
+}
+
+func combineVerdicts(a, b Verdict, all bool) Verdict {
+	switch {
+	case all && a == Included && b == Included:
+		return Included
+	case !all && (a == Included || b == Included):
+		return Included
+	case a == Excluded || b == Excluded:
+		return Excluded
+	}
+	return Unmatched
+}
+
+func ipColumn(line string) (string, bool) {
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		f := fields[i]
+		if !strings.HasPrefix(f, "[") || !strings.HasSuffix(f, "]") {
+			continue
+		}
+		if ip := net.ParseIP(stripBrackets(f)); ip != nil {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
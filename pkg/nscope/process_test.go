@@ -0,0 +1,194 @@
+package nscope
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processFixture runs process over testdata/name with the given scope and
+// returns the lines it printed.
+func processFixture(t *testing.T, name, scope string, opts Options, process func(io.Reader, io.Writer, *Scope, Options) (Stats, error)) ([]string, Stats) {
+	t.Helper()
+	m, err := ParseScope(strings.NewReader(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if opts.MaxHostLength == 0 {
+		opts.MaxHostLength = DefaultMaxHostLength
+	}
+	var out strings.Builder
+	st, err := process(f, &out, m, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"), st
+}
+
+// checkFixture compares the printed lines and the count of lines rejected
+// for each reason with what is wanted.
+func checkFixture(t *testing.T, got []string, st Stats, want []string, rejects map[string]int) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("printed\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for reason, n := range rejects {
+		if st.Rejects[reason] != n {
+			t.Errorf("%d lines rejected as %s, want %d", st.Rejects[reason], reason, n)
+		}
+	}
+	total := 0
+	for _, n := range rejects {
+		total += n
+	}
+	if st.Invalid+st.Skipped != total {
+		t.Errorf("%d lines rejected, want %d", st.Invalid+st.Skipped, total)
+	}
+}
+
+func TestProcessLinesPathologicalInput(t *testing.T) {
+	scope, err := ParseScope(strings.NewReader("*.example.com\nstaging.*.example.com\n**.internal.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		"https://" + strings.Repeat("a:", 5<<20) + "example.com/",
+		strings.Repeat("a.", 50000) + "example.com",
+		"app.example.com",
+	}, "\n")
+
+	done := make(chan Stats, 1)
+	start := time.Now()
+	go func() {
+		st, err := ProcessLines(strings.NewReader(input), io.Discard, scope, Options{MaxHostLength: DefaultMaxHostLength})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- st
+	}()
+	select {
+	case st := <-done:
+		if st.Lines != 3 || st.Invalid != 2 || st.Included != 1 {
+			t.Errorf("got %d lines, %d invalid, %d included; want 3, 2, 1", st.Lines, st.Invalid, st.Included)
+		}
+		if n := st.Rejects[RejectTooLong]; n != 2 {
+			t.Errorf("%d lines rejected as too long, want 2", n)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("processing took %s", elapsed)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("processing pathological lines did not finish")
+	}
+}
+
+func TestProcessLinesPreProcess(t *testing.T) {
+	scope, err := ParseScope(strings.NewReader("*.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "[tool] https://app.example.com/login\n" +
+		"DROP [tool] https://api.example.com/\n" +
+		"  [tool] https://other.org/  \n"
+	var seen []string
+	opts := Options{
+		MaxHostLength: DefaultMaxHostLength,
+		PreProcess: func(line string) (string, bool) {
+			seen = append(seen, line)
+			if strings.HasPrefix(line, "DROP ") {
+				return "", false
+			}
+			return strings.TrimPrefix(strings.TrimSpace(line), "[tool] "), true
+		},
+	}
+	var out strings.Builder
+	st, err := ProcessLines(strings.NewReader(input), &out, scope, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[tool] https://app.example.com/login", "DROP [tool] https://api.example.com/", "  [tool] https://other.org/  "}
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Errorf("hook saw %q, want the raw lines %q", seen, want)
+	}
+	if got := out.String(); got != "[tool] https://app.example.com/login\n" {
+		t.Errorf("printed %q, want the original line", got)
+	}
+	if st.Skipped != 1 || st.Rejects[RejectFiltered] != 1 {
+		t.Errorf("got %d skipped lines, %d filtered; want 1 and 1", st.Skipped, st.Rejects[RejectFiltered])
+	}
+	if st.Included != 1 || st.Unmatched != 1 {
+		t.Errorf("got %d included and %d unmatched lines, want 1 and 1", st.Included, st.Unmatched)
+	}
+}
+
+func TestProcessLinesJSONInputWithIP(t *testing.T) {
+	scope, err := ParseScope(strings.NewReader("10.0.0.0/8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		record string
+		want   bool
+	}{
+		{`{"host":"x.com","ip":"10.1.1.1"}`, true},
+		{`{"host":"x.com","a":["10.2.2.2","192.0.2.1"]}`, true},
+		{`{"host":"x.com","ip":"192.0.2.1"}`, false},
+		{`{"host":"x.com","ip":"not an ip"}`, false},
+		{`{"host":"x.com"}`, false},
+		{`{"host":"10.3.3.3"}`, true},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		opts := Options{MaxHostLength: DefaultMaxHostLength, JSONField: ".host", WithIP: true}
+		if _, err := ProcessLines(strings.NewReader(tt.record+"\n"), &out, scope, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String() != ""; got != tt.want {
+			t.Errorf("%s: printed = %v, want %v", tt.record, got, tt.want)
+		}
+		if tt.want && out.String() != tt.record+"\n" {
+			t.Errorf("%s: printed %q, want the record unchanged", tt.record, out.String())
+		}
+	}
+}
+
+func TestProcessLinesNamedScopes(t *testing.T) {
+	var named []NamedScope
+	for _, s := range []struct{ name, scope string }{
+		{"acme", "example.com\n*.test.com\n"},
+		{"globex", "*.example.com\n!bad.example.com\n"},
+	} {
+		m, err := ParseScope(strings.NewReader(s.scope))
+		if err != nil {
+			t.Fatal(err)
+		}
+		named = append(named, NamedScope{Name: s.name, Scope: m})
+	}
+	input := "https://example.com/x\nsub.test.com\nbad.example.com\nfoo.example.com\nnothing.org\n"
+	want := "acme,globex\t1:https://example.com/x\x00acme\t2:sub.test.com\x00globex\t4:foo.example.com\x00"
+	for _, workers := range []int{1, 4} {
+		var out strings.Builder
+		opts := Options{MaxHostLength: DefaultMaxHostLength, Scopes: named, Workers: workers, NulSeparated: true, LineNumbers: true}
+		st, err := ProcessLines(strings.NewReader(strings.ReplaceAll(input, "\n", "\x00")), &out, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("workers %d: printed %q, want %q", workers, got, want)
+		}
+		if st.Included != 3 || st.Excluded != 1 || st.Unmatched != 1 {
+			t.Errorf("workers %d: stats %+v, want 3 included, 1 excluded, 1 unmatched", workers, st)
+		}
+		if len(st.ByScope) != 2 || st.ByScope[0].Included != 2 || st.ByScope[1].Included != 2 || st.ByScope[1].Excluded != 1 {
+			t.Errorf("workers %d: per-scope stats %+v", workers, st.ByScope)
+		}
+	}
+}
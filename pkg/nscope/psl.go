@@ -0,0 +1,98 @@
+package nscope
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+func isPublicSuffix(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	ps, icann := publicsuffix.PublicSuffix(host)
+	return ps == host && (icann || strings.Contains(host, "."))
+}
+
+// matchTLDWildcard reports whether host is the name of a TLD wildcard entry
+// followed by a public suffix, or with *. in base, a subdomain of one.
+func (e *scopeEntry) matchTLDWildcard(host string) bool {
+	brand, sub := strings.CutPrefix(e.base, "*.")
+	for s := host; ; {
+		if rest, ok := strings.CutPrefix(s, brand+"."); ok && isPublicSuffix(rest) && (s != host || !e.noApex) {
+			return true
+		}
+		if !sub {
+			return false
+		}
+		dot := strings.IndexByte(s, '.')
+		if dot == -1 {
+			return false
+		}
+		s = s[dot+1:]
+	}
+}
+
+func (e *scopeEntry) coversPublicSuffix() bool {
+	if e.exclude || (e.kind != scopeExact && e.kind != scopeLeadingWildcard) {
+		return false
+	}
+	return net.ParseIP(e.base) == nil && isPublicSuffix(e.base)
+}
+
+func (m *Scope) PublicSuffixEntries() []Rule {
+	var rules []Rule
+	for i := range m.entries {
+		if m.entries[i].coversPublicSuffix() {
+			rules = append(rules, m.ruleAt(i))
+		}
+	}
+	return rules
+}
+
+func RegistrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	d, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return host
+	}
+	return d
+}
+
+type etld1Encoder struct {
+	w      io.Writer
+	counts map[string]int
+}
+
+func (e *etld1Encoder) Start(w io.Writer) {
+	e.w = w
+	e.counts = make(map[string]int)
+}
+
+func (e *etld1Encoder) Encode(res Result) error {
+	e.counts[RegistrableDomain(res.Host)]++
+	return nil
+}
+
+func (e *etld1Encoder) Finish() error {
+	domains := make([]string, 0, len(e.counts))
+	for d := range e.counts {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if e.counts[domains[i]] != e.counts[domains[j]] {
+			return e.counts[domains[i]] > e.counts[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	for _, d := range domains {
+		if _, err := fmt.Fprintf(e.w, "%d\t%s\n", e.counts[d], d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
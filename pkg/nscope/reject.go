@@ -0,0 +1,40 @@
+package nscope
+
+// Reasons reported in Result.Reject for lines that were skipped or whose
+// host could not be extracted.
+const (
+	RejectEmpty    = "empty"
+	RejectComment  = "comment"
+	RejectFiltered = "filtered"
+	RejectIgnored  = "ignored record"
+	RejectNoField  = "missing field"
+	RejectRecord   = "bad record"
+	RejectNoHost   = "no host"
+	RejectBadURL   = "bad URL"
+	RejectBadHost  = "bad host"
+	RejectTooLong  = "too long"
+	RejectIDNA     = "idna"
+)
+
+// skipLine marks a line that carries no target, such as a blank line.
+func skipLine(res *Result, st *Stats, reason string) bool {
+	res.Skipped, res.Reject = true, reason
+	st.Skipped++
+	st.countReject(reason)
+	return false
+}
+
+// rejectLine marks a line whose host could not be extracted.
+func rejectLine(res *Result, st *Stats, reason string) bool {
+	res.Invalid, res.Reject = true, reason
+	st.Invalid++
+	st.countReject(reason)
+	return false
+}
+
+func (st *Stats) countReject(reason string) {
+	if st.Rejects == nil {
+		st.Rejects = make(map[string]int)
+	}
+	st.Rejects[reason]++
+}
@@ -0,0 +1,99 @@
+package nscope
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func isRemoteScope(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// fetchRemoteScope downloads a scope file. With a cache directory the body
+// and its ETag are kept there, and later loads revalidate them with
+// If-None-Match so an unchanged file is not downloaded again.
+func fetchRemoteScope(url string, lo LoadOptions) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range lo.Header {
+		req.Header[k] = v
+	}
+	var bodyPath, etagPath string
+	if lo.CacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		key := hex.EncodeToString(sum[:16])
+		bodyPath = filepath.Join(lo.CacheDir, key+".scope")
+		etagPath = filepath.Join(lo.CacheDir, key+".etag")
+		if etag, err := os.ReadFile(etagPath); err == nil {
+			if _, err := os.Stat(bodyPath); err == nil {
+				req.Header.Set("If-None-Match", string(etag))
+			}
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching scope: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if bodyPath != "" {
+			return os.ReadFile(bodyPath)
+		}
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("fetching scope: %w", err)
+		}
+		if bodyPath != "" {
+			if err := cacheRemoteScope(bodyPath, etagPath, body, resp.Header.Get("ETag")); err != nil {
+				return nil, err
+			}
+		}
+		return body, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("fetching scope: %s: %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func cacheRemoteScope(bodyPath, etagPath string, body []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(bodyPath), 0o700); err != nil {
+		return err
+	}
+	os.Remove(etagPath)
+	if err := writeFileAtomic(bodyPath, body); err != nil {
+		return err
+	}
+	if etag == "" {
+		return nil
+	}
+	return writeFileAtomic(etagPath, []byte(etag))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
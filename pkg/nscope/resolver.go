@@ -0,0 +1,493 @@
+package nscope
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	systemDNSTTL   = 5 * time.Minute
+	negativeDNSTTL = time.Minute
+	dnsTimeout     = 5 * time.Second
+)
+
+var errNXDomain = errors.New("no such host")
+
+type dnsAnswer struct {
+	values []string
+	ttl    time.Duration
+}
+
+type dnsUpstream interface {
+	exchange(ctx context.Context, name string, qtype dnsmessage.Type) (dnsAnswer, error)
+}
+
+type ResolverStats struct {
+	Queries   int64
+	CacheHits int64
+	Errors    int64
+	NXDomain  int64
+}
+
+type Resolver struct {
+	upstreams []dnsUpstream
+	next      atomic.Uint64
+	sem       chan struct{}
+	cache     *dnsCache
+
+	queries   atomic.Int64
+	cacheHits atomic.Int64
+	errs      atomic.Int64
+	nxdomain  atomic.Int64
+}
+
+func NewResolver(spec string, concurrency, cacheSize int) (*Resolver, error) {
+	ups, err := parseUpstreams(spec)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Resolver{
+		upstreams: ups,
+		sem:       make(chan struct{}, concurrency),
+		cache:     newDNSCache(cacheSize),
+	}, nil
+}
+
+func parseUpstreams(spec string) ([]dnsUpstream, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "system" {
+		return []dnsUpstream{systemUpstream{}}, nil
+	}
+	var ups []dnsUpstream
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "":
+			continue
+		case s == "system":
+			ups = append(ups, systemUpstream{})
+		case strings.HasPrefix(s, "https://"):
+			ups = append(ups, &dohUpstream{url: s, client: &http.Client{Timeout: dnsTimeout}})
+		default:
+			addr := strings.TrimPrefix(s, "udp:")
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+			}
+			ups = append(ups, udpUpstream{addr: addr})
+		}
+	}
+	if len(ups) == 0 {
+		return nil, fmt.Errorf("no resolvers in %q", spec)
+	}
+	return ups, nil
+}
+
+func (r *Resolver) Stats() ResolverStats {
+	return ResolverStats{
+		Queries:   r.queries.Load(),
+		CacheHits: r.cacheHits.Load(),
+		Errors:    r.errs.Load(),
+		NXDomain:  r.nxdomain.Load(),
+	}
+}
+
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	var firstErr error
+	for _, qt := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		vals, err := r.lookup(ctx, host, qt)
+		if err != nil {
+			if firstErr == nil || errors.Is(firstErr, errNXDomain) {
+				firstErr = err
+			}
+			continue
+		}
+		for _, v := range vals {
+			if ip := net.ParseIP(v); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return ips, nil
+}
+
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	vals, err := r.lookup(ctx, host, dnsmessage.TypeCNAME)
+	if err != nil || len(vals) == 0 {
+		return "", err
+	}
+	return vals[0], nil
+}
+
+func (r *Resolver) LookupAddr(ctx context.Context, ip string) ([]string, error) {
+	return r.lookup(ctx, ip, dnsmessage.TypePTR)
+}
+
+func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Type) ([]string, error) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	key := qtype.String() + " " + name
+	if vals, err, ok := r.cache.get(key); ok {
+		r.cacheHits.Add(1)
+		return vals, err
+	}
+
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.sem }()
+
+	r.queries.Add(1)
+	up := r.upstreams[r.next.Add(1)%uint64(len(r.upstreams))]
+	ans, err := up.exchange(ctx, name, qtype)
+	switch {
+	case errors.Is(err, errNXDomain):
+		r.nxdomain.Add(1)
+		r.cache.put(key, nil, errNXDomain, negativeDNSTTL)
+		return nil, errNXDomain
+	case err != nil:
+		r.errs.Add(1)
+		return nil, err
+	}
+	r.cache.put(key, ans.values, nil, ans.ttl)
+	return ans.values, nil
+}
+
+type systemUpstream struct{}
+
+func (systemUpstream) exchange(ctx context.Context, name string, qtype dnsmessage.Type) (dnsAnswer, error) {
+	var vals []string
+	var err error
+	switch qtype {
+	case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+		network := "ip4"
+		if qtype == dnsmessage.TypeAAAA {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = net.DefaultResolver.LookupIP(ctx, network, name)
+		for _, ip := range ips {
+			vals = append(vals, ip.String())
+		}
+	case dnsmessage.TypeCNAME:
+		var cname string
+		cname, err = net.DefaultResolver.LookupCNAME(ctx, name)
+		if cname = strings.TrimSuffix(cname, "."); err == nil && cname != name {
+			vals = []string{cname}
+		}
+	case dnsmessage.TypePTR:
+		var names []string
+		names, err = net.DefaultResolver.LookupAddr(ctx, name)
+		for _, n := range names {
+			vals = append(vals, strings.TrimSuffix(n, "."))
+		}
+	default:
+		return dnsAnswer{}, fmt.Errorf("unsupported query type %v", qtype)
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return dnsAnswer{}, errNXDomain
+	}
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	return dnsAnswer{values: vals, ttl: systemDNSTTL}, nil
+}
+
+type udpUpstream struct {
+	addr string
+}
+
+func (u udpUpstream) exchange(ctx context.Context, name string, qtype dnsmessage.Type) (dnsAnswer, error) {
+	q, id, err := buildDNSQuery(name, qtype)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", u.addr)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if _, err := conn.Write(q); err != nil {
+		return dnsAnswer{}, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	ans, err := parseDNSResponse(buf[:n], id, qtype)
+	if errors.Is(err, errTruncated) {
+		return u.exchangeTCP(ctx, q, id, qtype)
+	}
+	return ans, err
+}
+
+func (u udpUpstream) exchangeTCP(ctx context.Context, q []byte, id uint16, qtype dnsmessage.Type) (dnsAnswer, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.addr)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	msg := make([]byte, 2+len(q))
+	binary.BigEndian.PutUint16(msg, uint16(len(q)))
+	copy(msg[2:], q)
+	if _, err := conn.Write(msg); err != nil {
+		return dnsAnswer{}, err
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return dnsAnswer{}, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return dnsAnswer{}, err
+	}
+	return parseDNSResponse(resp, id, qtype)
+}
+
+type dohUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func (d *dohUpstream) exchange(ctx context.Context, name string, qtype dnsmessage.Type) (dnsAnswer, error) {
+	q, _, err := buildDNSQuery(name, qtype)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	// RFC 8484 recommends a zero ID so responses are cache friendly.
+	binary.BigEndian.PutUint16(q, 0)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(q))
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dnsAnswer{}, fmt.Errorf("%s: %s", d.url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	return parseDNSResponse(body, 0, qtype)
+}
+
+var errTruncated = errors.New("truncated DNS response")
+
+func buildDNSQuery(name string, qtype dnsmessage.Type) ([]byte, uint16, error) {
+	if qtype == dnsmessage.TypePTR {
+		rev, err := reverseName(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		name = rev
+	}
+	qn, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	id := uint16(rand.N(1 << 16))
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qn, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	msg, err := b.Finish()
+	return msg, id, err
+}
+
+func parseDNSResponse(msg []byte, id uint16, qtype dnsmessage.Type) (dnsAnswer, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return dnsAnswer{}, err
+	}
+	if h.ID != id {
+		return dnsAnswer{}, errors.New("DNS response ID mismatch")
+	}
+	if h.Truncated {
+		return dnsAnswer{}, errTruncated
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return dnsAnswer{}, errNXDomain
+	default:
+		return dnsAnswer{}, fmt.Errorf("DNS server returned %v", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return dnsAnswer{}, err
+	}
+	ans := dnsAnswer{ttl: negativeDNSTTL}
+	first := true
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return dnsAnswer{}, err
+		}
+		if rh.Type != qtype {
+			if err := p.SkipAnswer(); err != nil {
+				return dnsAnswer{}, err
+			}
+			continue
+		}
+		var v string
+		switch qtype {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return dnsAnswer{}, err
+			}
+			v = net.IP(r.A[:]).String()
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return dnsAnswer{}, err
+			}
+			v = net.IP(r.AAAA[:]).String()
+		case dnsmessage.TypeCNAME:
+			r, err := p.CNAMEResource()
+			if err != nil {
+				return dnsAnswer{}, err
+			}
+			v = strings.TrimSuffix(r.CNAME.String(), ".")
+		case dnsmessage.TypePTR:
+			r, err := p.PTRResource()
+			if err != nil {
+				return dnsAnswer{}, err
+			}
+			v = strings.TrimSuffix(r.PTR.String(), ".")
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return dnsAnswer{}, err
+			}
+			continue
+		}
+		ans.values = append(ans.values, strings.ToLower(v))
+		if ttl := time.Duration(rh.TTL) * time.Second; first || ttl < ans.ttl {
+			ans.ttl = ttl
+		}
+		first = false
+	}
+	return ans, nil
+}
+
+func reverseName(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", fmt.Errorf("invalid IP address %q", ip)
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+	const hexDigits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(addr) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[addr[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[addr[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String(), nil
+}
+
+type dnsCache struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type dnsCacheItem struct {
+	key     string
+	values  []string
+	err     error
+	expires time.Time
+}
+
+func newDNSCache(max int) *dnsCache {
+	return &dnsCache{max: max, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *dnsCache) get(key string) ([]string, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, nil, false
+	}
+	it := el.Value.(*dnsCacheItem)
+	if time.Now().After(it.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, nil, false
+	}
+	c.ll.MoveToFront(el)
+	return it.values, it.err, true
+}
+
+func (c *dnsCache) put(key string, values []string, err error, ttl time.Duration) {
+	if c.max <= 0 || ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it := &dnsCacheItem{key: key, values: values, err: err, expires: time.Now().Add(ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = it
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(it)
+	for c.ll.Len() > c.max {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*dnsCacheItem).key)
+	}
+}
@@ -0,0 +1,803 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+type scopeKind uint8
+
+const (
+	scopeExact scopeKind = iota
+	scopeLeadingWildcard
+	scopePatternWildcard
+	scopeCIDR
+	scopeRange
+	scopeRegex
+	scopeASN
+	// scopeTLDWildcard entries, example.* or *.example.*, keep the name
+	// before .* in base, with its *. prefix when subdomains match too.
+	scopeTLDWildcard
+	// scopeAny entries, a bare *, match every host and address.
+	scopeAny
+)
+
+type scopeEntry struct {
+	raw           string
+	base          string
+	port          string
+	patternLabels []string
+	network       netip.Prefix
+	ports         []portRange
+	scheme        string
+	path          string
+	file          string
+	line          int
+	meta          *entryMeta
+	kind          scopeKind
+	altered       bool
+	idnaFailed    bool
+	exclude       bool
+	noApex        bool
+}
+
+// entryMeta holds the fields that most entries leave unset, so that they
+// cost one pointer per entry rather than their full size.
+type entryMeta struct {
+	re       *regexp.Regexp
+	prefixes []netip.Prefix
+	tags     []string
+	note     string
+	severity string
+	expires  time.Time
+}
+
+var noMeta entryMeta
+
+// info returns the rarely set fields of e for reading.
+func (e *scopeEntry) info() *entryMeta {
+	if e.meta == nil {
+		return &noMeta
+	}
+	return e.meta
+}
+
+// setInfo returns the rarely set fields of e for writing. They are copied
+// first because entries copied between scopes share them.
+func (e *scopeEntry) setInfo() *entryMeta {
+	meta := *e.info()
+	e.meta = &meta
+	return e.meta
+}
+
+type Scope struct {
+	entries  []scopeEntry
+	index    *scopeIndex
+	excludes *scopeIndex
+	priority RulePriority
+	used     []atomic.Bool
+	// asOf is when the indexes were built; entries expiring by then are
+	// left out of them.
+	asOf time.Time
+}
+
+const (
+	DefaultMaxHostLength = 253
+	maxHostLabels        = 127
+)
+
+type Verdict int
+
+const (
+	Unmatched Verdict = iota
+	Included
+	Excluded
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Included:
+		return "included"
+	case Excluded:
+		return "excluded"
+	}
+	return "unmatched"
+}
+
+type LoadOptions struct {
+	Compact bool
+	// KeepRaw keeps the text of each entry as written under Compact, for
+	// callers that report the deciding rule.
+	KeepRaw            bool
+	ASN                ASNProvider
+	RejectPublicSuffix bool
+	WildcardSkipsApex  bool
+	StrictIDNA         bool
+	Header             http.Header
+	CacheDir           string
+	// KeepASNs leaves as: entries unexpanded, matching nothing, for tools
+	// that only inspect the scope.
+	KeepASNs bool
+}
+
+type Options struct {
+	Reverse       bool
+	MaxHostLength int
+	WithIP        bool
+	MatchAll      bool
+	Annotate      bool
+	Rules         bool
+	Explain       bool
+	TrackUsage    bool
+	Workers       int
+	Unordered     bool
+	Resolve       bool
+	ReverseDNS    bool
+	Resolver      *Resolver
+	StrictIDNA    bool
+	Refang        bool
+	Grep          bool
+	Delimiter     string
+	Field         int
+	JSONField     string
+	Input         string
+	OnlyMatching  bool
+	WithPort      bool
+	NulSeparated  bool
+	LineNumbers   bool
+	Color         bool
+	// LineSource, if set, maps a line number to the file the line was read
+	// from and its line number there, for LineNumbers output.
+	LineSource    func(lineNo int) (string, int)
+	Limit         int
+	LiveScope     *atomic.Pointer[Scope]
+	Warnings      io.Writer
+	InScopeOut    io.Writer
+	OutOfScopeOut io.Writer
+	ExcludedOut   io.Writer
+	UnmatchedOut  io.Writer
+	RejectsOut    io.Writer
+	PreProcess    func(line string) (string, bool)
+	Encoder       OutputEncoder
+	// Scopes, if set, matches every line against each of these scopes
+	// instead of the one passed in.
+	Scopes []NamedScope
+}
+
+type Stats struct {
+	Lines     int
+	Skipped   int
+	Invalid   int
+	Parsed    int
+	Fallback  int
+	Included  int
+	Excluded  int
+	Unmatched int
+
+	IDNARejected int
+	// Rejects counts skipped and unparsable lines by reason.
+	Rejects map[string]int
+	// ByScope counts the verdicts of each of Options.Scopes.
+	ByScope []Stats
+}
+
+func (st *Stats) add(o Stats) {
+	st.Lines += o.Lines
+	st.Skipped += o.Skipped
+	st.Invalid += o.Invalid
+	st.Parsed += o.Parsed
+	st.Fallback += o.Fallback
+	st.Included += o.Included
+	st.Excluded += o.Excluded
+	st.Unmatched += o.Unmatched
+	st.IDNARejected += o.IDNARejected
+	for reason, n := range o.Rejects {
+		if st.Rejects == nil {
+			st.Rejects = make(map[string]int)
+		}
+		st.Rejects[reason] += n
+	}
+	if st.ByScope == nil && o.ByScope != nil {
+		st.ByScope = make([]Stats, len(o.ByScope))
+	}
+	for i := range o.ByScope {
+		st.ByScope[i].add(o.ByScope[i])
+	}
+}
+
+func (st *Stats) Count(v Verdict) {
+	switch v {
+	case Included:
+		st.Included++
+	case Excluded:
+		st.Excluded++
+	default:
+		st.Unmatched++
+	}
+}
+
+func LoadScope(path string, lo LoadOptions) (*Scope, error) {
+	if isRemoteScope(path) {
+		b, err := fetchRemoteScope(path, lo)
+		if err != nil {
+			return nil, err
+		}
+		r, err := Decompress(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readScope(r, path, lo)
+	}
+	if platform, program, ok := strings.Cut(path, ":"); ok && scopeFetchers[platform] != nil {
+		if _, err := os.Stat(path); err != nil {
+			text, err := FetchScopeText(platform, program)
+			if err != nil {
+				return nil, err
+			}
+			return readScope(strings.NewReader(text), path, lo)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := Decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readScope(r, path, lo)
+}
+
+func readScope(r io.Reader, path string, lo LoadOptions) (*Scope, error) {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(len(compiledMagic)); string(b) == compiledMagic {
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		m := &Scope{}
+		if err := m.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if documentStart(br) == '<' {
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return ParseZAPContext(b, path)
+	}
+	if documentStart(br) == '{' {
+		var raw json.RawMessage
+		if err := json.NewDecoder(br).Decode(&raw); err != nil {
+			return nil, err
+		}
+		if isBurpDocument(raw) {
+			return ParseBurpScope(raw, path)
+		}
+		m := &Scope{}
+		if err := json.Unmarshal(raw, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if isYAMLScope(br, path) {
+		return readYAMLScope(br, path, lo)
+	}
+
+	var out []scopeEntry
+	var pk *scopePacker
+	if lo.Compact {
+		pk = newScopePacker(lo.KeepRaw)
+	}
+	err := scanScopeText(br, func(lineNo int, rule, comment string) error {
+		ent, err := lo.parseEntry(rule, path, lineNo)
+		if err != nil {
+			return err
+		}
+		if tags := commentTags(comment); tags != nil {
+			ent.setInfo().tags = tags
+		}
+		if pk != nil {
+			pk.pack(&ent)
+		}
+		out = append(out, ent)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pk != nil {
+		// Drop the spare capacity left by append.
+		out = slices.Clone(out)
+	}
+	m := newScope(out)
+	if err := m.expandASNs(lo); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// scanScopeText calls fn with the rule and trailing comment of every line of
+// a text scope that holds a rule.
+func scanScopeText(r io.Reader, fn func(lineNo int, rule, comment string) error) error {
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		trimmed := strings.TrimSpace(sc.Text())
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		var comment string
+		if idx := strings.Index(trimmed, "#"); idx != -1 {
+			trimmed, comment = strings.TrimSpace(trimmed[:idx]), trimmed[idx+1:]
+		}
+		if trimmed == "" {
+			continue
+		}
+		if err := fn(lineNo, trimmed, comment); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// parseEntry parses one scope rule read from path at line and applies the
+// load options to it.
+func (lo LoadOptions) parseEntry(text, path string, line int) (scopeEntry, error) {
+	ent, err := parseScopeLine(text)
+	if err != nil {
+		return ent, fmt.Errorf("%s:%d: %w", path, line, err)
+	}
+	if lo.StrictIDNA && ent.idnaFailed {
+		return ent, fmt.Errorf("%s:%d: %q fails IDNA conversion", path, line, text)
+	}
+	if lo.RejectPublicSuffix && ent.coversPublicSuffix() {
+		return ent, fmt.Errorf("%s:%d: %q covers the public suffix %s", path, line, text, ent.base)
+	}
+	ent.noApex = lo.WildcardSkipsApex && (ent.kind == scopeLeadingWildcard || ent.kind == scopeTLDWildcard && strings.HasPrefix(ent.base, "*."))
+	ent.file = path
+	ent.line = line
+	return ent, nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+func commentTags(comment string) []string {
+	var tags []string
+	for _, f := range strings.Fields(strings.ReplaceAll(comment, "#", " ")) {
+		if t, ok := strings.CutPrefix(f, "tag:"); ok && t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func ParseScope(r io.Reader) (*Scope, error) {
+	return readScope(r, "", LoadOptions{})
+}
+
+// ReadScope parses a scope from r like LoadScope does for a file; name is
+// reported as the file of each rule.
+func ReadScope(r io.Reader, name string, lo LoadOptions) (*Scope, error) {
+	return readScope(r, name, lo)
+}
+
+func (m *Scope) AddExclusions(x *Scope) {
+	if len(x.entries) == 0 {
+		return
+	}
+	for _, e := range x.entries {
+		e.exclude = true
+		m.entries = append(m.entries, e)
+	}
+	m.buildIndexes()
+}
+
+// Merge adds the entries of o to m, as if both had been read from one file.
+func (m *Scope) Merge(o *Scope) {
+	m.entries = append(m.entries, o.entries...)
+	m.buildIndexes()
+}
+
+func (m *Scope) SetRulePriority(p RulePriority) {
+	m.priority = p
+}
+
+func documentStart(br *bufio.Reader) byte {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return 0
+		}
+		switch c := b[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return c
+		}
+	}
+}
+
+func parseScopeLine(line string) (scopeEntry, error) {
+	var idnaFailed bool
+	rule, exclude := strings.CutPrefix(strings.TrimSpace(line), "!")
+	rule = strings.TrimSpace(rule)
+	var e scopeEntry
+	if expr, ok := strings.CutPrefix(rule, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return scopeEntry{}, err
+		}
+		e = scopeEntry{kind: scopeRegex, base: expr, meta: &entryMeta{re: re}}
+	} else if expr, ok := cutPrefixFold(rule, "as:"); ok {
+		asn, err := parseASN(expr)
+		if err != nil {
+			return scopeEntry{}, err
+		}
+		e = scopeEntry{kind: scopeASN, base: strconv.FormatUint(uint64(asn), 10)}
+	} else {
+		scheme, rest, ok := strings.Cut(rule, "://")
+		if !ok {
+			scheme, rest = "", rule
+		} else if scheme == "" {
+			return scopeEntry{}, fmt.Errorf("missing scheme in %q", rule)
+		}
+		if end := strings.IndexAny(rest, "?#"); ok && end != -1 {
+			rest = rest[:end]
+		}
+		rest, path := splitScopePath(rest)
+		e = parseScopeRule(rest, &idnaFailed)
+		e.scheme = strings.ToLower(scheme)
+		if path != "/" {
+			e.path = path
+		}
+	}
+	if e.port != "" {
+		ports, err := parsePortSpec(e.port)
+		if err != nil {
+			return scopeEntry{}, err
+		}
+		e.ports = ports
+	}
+	e.raw = line
+	e.exclude = exclude
+	e.idnaFailed = idnaFailed
+	e.altered = !strings.EqualFold(strings.TrimSpace(line), e.canonical())
+	return e, nil
+}
+
+func splitScopePath(rule string) (string, string) {
+	i := strings.IndexByte(rule, '/')
+	if i == -1 {
+		return rule, ""
+	}
+	bits := rule[i+1:]
+	if end := strings.IndexAny(bits, ":]"); end != -1 {
+		bits = bits[:end]
+	}
+	if _, err := strconv.Atoi(bits); err == nil && isIPHost(strings.Trim(rule[:i], "[]")) {
+		return rule, ""
+	}
+	return rule[:i], rule[i:]
+}
+
+func asciiHost(h string, failed *bool) string {
+	if isCanonicalASCII(h) {
+		return h
+	}
+	ascii, err := idna.ToASCII(strings.ToLower(h))
+	if err != nil {
+		*failed = true
+		return h
+	}
+	return ascii
+}
+
+func parseScopeRule(line string, idnaFailed *bool) scopeEntry {
+	orig := line
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(line, ".")
+	if strings.Contains(line, "-") {
+		if e, ok := parseRangeEntry(line); ok {
+			e.raw = orig
+			return e
+		}
+	}
+	if strings.Contains(line, "/") {
+		if e, ok := parseCIDREntry(line); ok {
+			e.raw = orig
+			return e
+		}
+	}
+	if host, port := stripPort(line); strings.HasSuffix(host, ".*") {
+		brand, sub := strings.CutPrefix(strings.TrimSuffix(host, ".*"), "*.")
+		if brand != "" && !strings.Contains(brand, "*") {
+			brand = strings.ToLower(asciiHost(brand, idnaFailed))
+			if sub {
+				brand = "*." + brand
+			}
+			return scopeEntry{raw: orig, kind: scopeTLDWildcard, base: brand, port: port}
+		}
+	}
+	if host, port := stripPort(line); host == "*" {
+		return scopeEntry{raw: orig, kind: scopeAny, port: port}
+	}
+	if strings.HasPrefix(line, "*.") && !strings.Contains(line[2:], "*") {
+		without := strings.TrimPrefix(line, "*.")
+		host, port := stripPort(without)
+		host = strings.TrimSuffix(host, ".")
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = stripBrackets(host)
+		}
+		host = strings.ToLower(asciiHost(host, idnaFailed))
+		return scopeEntry{raw: orig, kind: scopeLeadingWildcard, base: host, port: port}
+	}
+	if strings.Contains(line, "*") {
+		labels := strings.Split(line, ".")
+		for i := range labels {
+			lbl := strings.TrimSpace(labels[i])
+			if lbl == "" {
+				labels[i] = lbl
+				continue
+			}
+			if strings.Contains(lbl, ":") {
+				h, p := stripPort(lbl)
+				h = strings.TrimSuffix(h, ".")
+				if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+					h = stripBrackets(h)
+				}
+				labels[i] = asciiHost(h, idnaFailed)
+				if p != "" {
+					labels = append(labels, "__PORT__:"+p)
+				}
+				continue
+			}
+			if !strings.Contains(lbl, "*") {
+				lbl = asciiHost(lbl, idnaFailed)
+			}
+			labels[i] = strings.ToLower(lbl)
+		}
+		var port string
+		if len(labels) > 0 {
+			last := labels[len(labels)-1]
+			if strings.HasPrefix(last, "__PORT__:") {
+				port = strings.TrimPrefix(last, "__PORT__:")
+				labels = labels[:len(labels)-1]
+			}
+		}
+		return scopeEntry{raw: orig, kind: scopePatternWildcard, patternLabels: labels, port: port}
+	}
+
+	host, port := stripPort(line)
+	host = strings.TrimSuffix(host, ".")
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = stripBrackets(host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return scopeEntry{raw: orig, kind: scopeExact, base: ip.String(), port: port}
+	}
+	host = strings.ToLower(asciiHost(host, idnaFailed))
+	return scopeEntry{raw: orig, kind: scopeExact, base: host, port: port}
+}
+
+func parseCIDREntry(line string) (scopeEntry, bool) {
+	s, port := line, ""
+	if strings.HasPrefix(s, "[") {
+		if end := strings.LastIndex(s, "]"); end != -1 {
+			s, port = s[1:end], strings.TrimPrefix(s[end+1:], ":")
+		}
+	} else if idx := strings.LastIndexByte(s, ':'); idx > strings.IndexByte(s, '/') && !strings.Contains(s[:idx], ":") {
+		s, port = s[:idx], s[idx+1:]
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return scopeEntry{}, false
+	}
+	p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-unmapBits(p.Addr())).Masked()
+	return scopeEntry{kind: scopeCIDR, base: p.String(), network: p, port: port}, true
+}
+
+func unmapBits(a netip.Addr) int {
+	if a.Is4In6() {
+		return 96
+	}
+	return 0
+}
+
+type Rule struct {
+	Index    int
+	Text     string
+	Kind     string
+	Exclude  bool
+	File     string
+	Line     int
+	Tags     []string
+	Note     string
+	Severity string
+	Expires  time.Time
+}
+
+func (m *Scope) Match(host, port string) (bool, Rule) {
+	h, err := CanonicalHost(host)
+	if err != nil {
+		return false, Rule{Index: -1}
+	}
+	t := target{host: h, port: port}
+	i, ok := m.rule(t)
+	if !ok {
+		return false, Rule{Index: -1}
+	}
+	return m.verdict(t) == Included, m.ruleAt(i)
+}
+
+func (m *Scope) ruleAt(i int) Rule {
+	e := &m.entries[i]
+	return Rule{
+		Index:    i,
+		Text:     e.label(),
+		Kind:     e.kind.String(),
+		Exclude:  e.exclude,
+		File:     e.file,
+		Line:     e.line,
+		Tags:     e.info().tags,
+		Note:     e.info().note,
+		Severity: e.info().severity,
+		Expires:  e.info().expires,
+	}
+}
+
+// Location returns where the rule was defined, as file:line or "line N".
+func (r Rule) Location() string {
+	if r.File == "" {
+		return fmt.Sprintf("line %d", r.Line)
+	}
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+func (r Rule) Describe() string {
+	if r.Index < 0 {
+		return "no rule"
+	}
+	return r.Location() + ": " + r.Text
+}
+
+func (m *Scope) Verdict(host, port string) Verdict {
+	return m.verdict(target{host: host, port: port})
+}
+
+func (m *Scope) verdict(t target) Verdict {
+	if m.excludes.match(t, m.entries) {
+		return Excluded
+	}
+	if m.index.match(t, m.entries) {
+		return Included
+	}
+	return Unmatched
+}
+
+func matchPatternWildcard(host string, pattern []string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if strings.Count(host, ".")+1 != len(pattern) && !slices.Contains(pattern, "**") {
+		return false
+	}
+	return matchLabels(strings.Split(host, "."), pattern)
+}
+
+// matchLabels matches host labels against pattern labels, where ** stands for
+// one or more labels. Patterns with ** are matched by dynamic programming over
+// host positions, so the cost stays O(labels × pattern labels) however many **
+// the pattern has.
+func matchLabels(hl, pattern []string) bool {
+	if !slices.Contains(pattern, "**") {
+		if len(hl) != len(pattern) {
+			return false
+		}
+		for i, p := range pattern {
+			if !matchLabel(strings.ToLower(strings.TrimSpace(p)), hl[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	// cur[i] reports whether the pattern labels consumed so far match hl[:i].
+	cur, next := make([]bool, len(hl)+1), make([]bool, len(hl)+1)
+	cur[0] = true
+	for k := 0; k < len(pattern); k++ {
+		clear(next)
+		if pattern[k] == "**" {
+			// A run of n consecutive ** is one step matching n or more labels.
+			n := 1
+			for k+1 < len(pattern) && pattern[k+1] == "**" {
+				k++
+				n++
+			}
+			seen := false
+			for i := n; i <= len(hl); i++ {
+				seen = seen || cur[i-n]
+				next[i] = seen
+			}
+		} else {
+			p := strings.ToLower(strings.TrimSpace(pattern[k]))
+			for i := 1; i <= len(hl); i++ {
+				next[i] = cur[i-1] && matchLabel(p, hl[i-1])
+			}
+		}
+		cur, next = next, cur
+	}
+	return cur[len(hl)]
+}
+
+func matchLabel(p, l string) bool {
+	switch {
+	case p == "*":
+		return l != ""
+	case strings.Contains(p, "*"):
+		return matchLabelGlob(p, strings.ToLower(l))
+	}
+	return strings.EqualFold(l, p)
+}
+
+// matchLabelGlob matches one label against a pattern label with * inside it,
+// such as dev-*, where each * stands for any run of characters, possibly
+// none.
+func matchLabelGlob(pattern, label string) bool {
+	parts := strings.Split(pattern, "*")
+	first, last := parts[0], parts[len(parts)-1]
+	if !strings.HasPrefix(label, first) {
+		return false
+	}
+	label = label[len(first):]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(label, p)
+		if i < 0 {
+			return false
+		}
+		label = label[i+len(p):]
+	}
+	return strings.HasSuffix(label, last)
+}
+
+// labelRegexp returns a regular expression for one pattern label, where class
+// is the character class a * may stand for.
+func labelRegexp(l, class string) string {
+	switch l {
+	case "*":
+		return class + "+"
+	case "**":
+		return class + `+(?:\.` + class + "+)*"
+	}
+	parts := strings.Split(l, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return strings.Join(parts, class+"*")
+}
@@ -0,0 +1,103 @@
+package nscope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPatternWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"staging.*.example.com", "staging.eu.example.com", true},
+		{"staging.*.example.com", "staging.example.com", false},
+		{"dev-*.example.com", "dev-api.example.com", true},
+		{"dev-*.example.com", "prod.example.com", false},
+		{"**.internal.example.com", "a.b.internal.example.com", true},
+		{"**.internal.example.com", "internal.example.com", false},
+		{"api.**.prod.example.com", "api.eu.west.prod.example.com", true},
+		{"api.**.prod.example.com", "api.prod.example.com", false},
+		{"**.**.x.com", "a.x.com", false},
+		{"**.**.x.com", "a.b.x.com", true},
+		{"*.**.a.com", "b.a.a.com", true},
+		{"*.**.a.com", "a.a.com", false},
+	}
+	for _, tt := range tests {
+		e, err := parseScopeLine(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseScopeLine(%q): %v", tt.pattern, err)
+		}
+		if e.kind != scopePatternWildcard {
+			t.Fatalf("parseScopeLine(%q) kind = %v, want pattern_wildcard", tt.pattern, e.kind)
+		}
+		if got := matchPatternWildcard(tt.host, e.patternLabels); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPatternWildcardManyDoubleStars(t *testing.T) {
+	e, err := parseScopeLine("**.**.**.**.**.**.x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.Repeat("a.", 120) + "y.com"
+	if matchPatternWildcard(host, e.patternLabels) {
+		t.Errorf("%q matched %q", e.raw, host)
+	}
+	host = strings.Repeat("a.", 120) + "x.com"
+	if !matchPatternWildcard(host, e.patternLabels) {
+		t.Errorf("%q did not match %q", e.raw, host)
+	}
+}
+
+func TestParseScopeLinePath(t *testing.T) {
+	tests := []struct {
+		line string
+		kind scopeKind
+		base string
+		path string
+	}{
+		{"year.com/2024", scopeExact, "year.com", "/2024"},
+		{"example.com/api/*", scopeExact, "example.com", "/api/*"},
+		{"10.0.0.0/8", scopeCIDR, "", ""},
+		{"2001:db8::/32", scopeCIDR, "", ""},
+	}
+	for _, tt := range tests {
+		e, err := parseScopeLine(tt.line)
+		if err != nil {
+			t.Fatalf("parseScopeLine(%q): %v", tt.line, err)
+		}
+		if e.kind != tt.kind || e.path != tt.path || tt.base != "" && e.base != tt.base {
+			t.Errorf("parseScopeLine(%q) = %v %q %q, want %v %q %q", tt.line, e.kind, e.base, e.path, tt.kind, tt.base, tt.path)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	m, err := ParseScope(strings.NewReader("*\n!admin.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"foo", "app.example.com", "a.b.c.example.org", "10.0.0.1", "::1"} {
+		if ok, rule := m.Match(host, ""); !ok || rule.Text != "*" {
+			t.Errorf("Match(%q) = %v %q, want true \"*\"", host, ok, rule.Text)
+		}
+	}
+	if ok, _ := m.Match("admin.example.com", ""); ok {
+		t.Error("excluded host admin.example.com matched")
+	}
+
+	m, err = ParseScope(strings.NewReader("*:8443\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := m.Match("app.example.com", "8443"); !ok {
+		t.Error("*:8443 did not match port 8443")
+	}
+	if ok, _ := m.Match("app.example.com", "443"); ok {
+		t.Error("*:8443 matched port 443")
+	}
+}
@@ -0,0 +1,145 @@
+package nscope
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sqliteBatchRows  = 1000
+	sqliteBatchDelay = time.Second
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS hosts (
+  host TEXT PRIMARY KEY,
+  first_seen TEXT NOT NULL,
+  last_seen TEXT NOT NULL,
+  matched_rule TEXT,
+  source_line TEXT
+);
+`
+
+// SQLiteSink upserts the hosts of the results it records into the hosts
+// table of a SQLite database, keeping the first time each host was seen.
+// Statements are piped to the sqlite3 command and committed in batches of
+// sqliteBatchRows, or sqliteBatchDelay after the first uncommitted row.
+type SQLiteSink struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	in      io.WriteCloser
+	w       *bufio.Writer
+	pending int
+	timer   *time.Timer
+	err     error
+}
+
+// OpenSQLite creates the hosts table in the database at path if needed and
+// starts sqlite3 to receive the upserts.
+func OpenSQLite(path string) (*SQLiteSink, error) {
+	if out, err := exec.Command("sqlite3", "-batch", "-bail", path, sqliteSchema).CombinedOutput(); err != nil {
+		if msg := strings.TrimPrefix(strings.TrimSpace(string(out)), "Error: "); msg != "" {
+			return nil, fmt.Errorf("opening %s: %s", path, msg)
+		}
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	cmd := exec.Command("sqlite3", "-batch", "-bail", path)
+	cmd.Stderr = os.Stderr
+	detach(cmd)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return &SQLiteSink{cmd: cmd, in: in, w: bufio.NewWriter(in)}, nil
+}
+
+// Record upserts the host of res, or every in-scope host of a line with
+// several.
+func (s *SQLiteSink) Record(res Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.pending == 0 {
+		s.w.WriteString("BEGIN;\n")
+		s.timer = time.AfterFunc(sqliteBatchDelay, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.commit()
+		})
+	}
+	hosts := res.Matches
+	if len(hosts) == 0 {
+		hosts = []string{res.Host}
+	}
+	now := sqlQuote(time.Now().UTC().Format(time.RFC3339))
+	rule := "NULL"
+	if res.Rule.Index >= 0 {
+		rule = sqlQuote(res.Rule.Text)
+	}
+	for _, h := range hosts {
+		fmt.Fprintf(s.w, "INSERT INTO hosts VALUES (%s, %s, %s, %s, %s) ON CONFLICT(host) DO UPDATE SET last_seen = excluded.last_seen, matched_rule = excluded.matched_rule, source_line = excluded.source_line;\n",
+			sqlQuote(h), now, now, rule, sqlQuote(res.Line))
+	}
+	if s.pending++; s.pending >= sqliteBatchRows {
+		s.commit()
+	}
+	return s.err
+}
+
+func (s *SQLiteSink) commit() {
+	if s.pending == 0 || s.err != nil {
+		return
+	}
+	s.timer.Stop()
+	s.pending = 0
+	s.w.WriteString("COMMIT;\n")
+	s.err = s.w.Flush()
+}
+
+// Close commits the rows recorded so far and waits for sqlite3 to exit.
+func (s *SQLiteSink) Close() error {
+	s.mu.Lock()
+	s.commit()
+	err := s.err
+	s.mu.Unlock()
+	s.in.Close()
+	if werr := s.cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("sqlite3: %w", werr)
+	}
+	return err
+}
+
+// Wrap returns an encoder that records every result in s before passing it
+// on to enc.
+func (s *SQLiteSink) Wrap(enc OutputEncoder) OutputEncoder {
+	return &sqliteEncoder{OutputEncoder: enc, sink: s}
+}
+
+type sqliteEncoder struct {
+	OutputEncoder
+	sink *SQLiteSink
+}
+
+func (e *sqliteEncoder) Encode(res Result) error {
+	if err := e.sink.Record(res); err != nil {
+		return err
+	}
+	return e.OutputEncoder.Encode(res)
+}
+
+// sqlQuote returns s as an SQL string literal. The sqlite3 command cannot
+// read NUL bytes, so they are dropped.
+func sqlQuote(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,104 @@
+package nscope
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateResult is the value a -format template is executed with.
+type TemplateResult struct {
+	LineNo  int
+	Line    string
+	Host    string
+	Port    string
+	Scheme  string
+	Path    string
+	IP      string
+	Verdict string
+	Matched bool
+	Matches []string
+	Rule    TemplateRule
+	Tags    []string
+	Scopes  []string
+}
+
+// TemplateRule describes the scope entry that decided a result. Index is -1
+// and the other fields are empty when no entry matched.
+type TemplateRule struct {
+	Raw      string
+	Index    int
+	Kind     string
+	Exclude  bool
+	File     string
+	Line     int
+	Location string
+	Tags     []string
+	Note     string
+	Severity string
+	Expires  time.Time
+}
+
+var templateFuncs = template.FuncMap{"join": strings.Join}
+
+type templateEncoder struct {
+	tmpl *template.Template
+	w    *bufio.Writer
+}
+
+// NewTemplateEncoder returns an encoder that writes each result by executing
+// text as a text/template with a TemplateResult, followed by a newline. The
+// function join is available, e.g. {{join .Tags ","}}.
+func NewTemplateEncoder(text string) (OutputEncoder, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &templateEncoder{tmpl: tmpl}, nil
+}
+
+func (e *templateEncoder) Start(w io.Writer) { e.w = bufio.NewWriter(w) }
+
+func (e *templateEncoder) Encode(res Result) error {
+	r := TemplateResult{
+		LineNo:  res.LineNo,
+		Line:    res.Line,
+		Host:    res.Host,
+		Port:    res.Port,
+		Scheme:  res.Scheme,
+		Path:    res.Path,
+		IP:      res.IP,
+		Verdict: res.Verdict.String(),
+		Matched: res.Verdict == Included,
+		Matches: res.Matches,
+		Rule:    TemplateRule{Index: res.Rule.Index},
+		Tags:    res.Rule.Tags,
+		Scopes:  res.Scopes,
+	}
+	if res.Rule.Index >= 0 {
+		r.Rule = TemplateRule{
+			Raw:      res.Rule.Text,
+			Index:    res.Rule.Index,
+			Kind:     res.Rule.Kind,
+			Exclude:  res.Rule.Exclude,
+			File:     res.Rule.File,
+			Line:     res.Rule.Line,
+			Location: res.Rule.Location(),
+			Tags:     res.Rule.Tags,
+			Note:     res.Rule.Note,
+			Severity: res.Rule.Severity,
+			Expires:  res.Rule.Expires,
+		}
+	}
+	if err := e.tmpl.Execute(e.w, r); err != nil {
+		return err
+	}
+	if err := e.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *templateEncoder) Finish() error { return e.w.Flush() }
@@ -0,0 +1,121 @@
+package nscope
+
+import "strings"
+
+// labelTrie indexes exact and leading-wildcard entries by their labels,
+// rightmost first, so that one walk down a host's labels finds the entries
+// for the host and for each of its parent domains.
+type labelTrie struct {
+	nodes []trieNode
+	edges map[trieEdge]int32
+	// more holds the entries of a node with several of one kind.
+	more [][]int
+}
+
+type trieEdge struct {
+	parent int32
+	label  string
+}
+
+// trieNode refers to its exact and its leading-wildcard entries each by an
+// entryRef.
+type trieNode struct {
+	exact, suffix entryRef
+}
+
+// entryRef is -1 for no entry, the index of a single entry, or -2-n for the
+// entries in labelTrie.more[n]. Most nodes have at most one of each kind.
+type entryRef int32
+
+const noEntry entryRef = -1
+
+func newLabelTrie() *labelTrie {
+	return &labelTrie{nodes: []trieNode{{noEntry, noEntry}}, edges: make(map[trieEdge]int32)}
+}
+
+// add indexes entry i under name, as a leading wildcard if suffix is set.
+func (tr *labelTrie) add(name string, i int, suffix bool) {
+	n := int32(0)
+	for rest := name; rest != ""; {
+		var label string
+		label, rest = lastLabel(rest)
+		edge := trieEdge{n, label}
+		child, ok := tr.edges[edge]
+		if !ok {
+			child = int32(len(tr.nodes))
+			tr.nodes = append(tr.nodes, trieNode{noEntry, noEntry})
+			tr.edges[edge] = child
+		}
+		n = child
+	}
+	ref := &tr.nodes[n].exact
+	if suffix {
+		ref = &tr.nodes[n].suffix
+	}
+	switch {
+	case *ref == noEntry:
+		*ref = entryRef(i)
+	case *ref >= 0:
+		tr.more = append(tr.more, []int{int(*ref), i})
+		*ref = entryRef(-1 - len(tr.more))
+	default:
+		tr.more[-2-*ref] = append(tr.more[-2-*ref], i)
+	}
+}
+
+// ids returns the entries ref refers to, keeping a single one in one.
+func (tr *labelTrie) ids(ref entryRef, one *[1]int) []int {
+	switch {
+	case ref == noEntry:
+		return nil
+	case ref >= 0:
+		one[0] = int(ref)
+		return one[:]
+	}
+	return tr.more[-2-ref]
+}
+
+// exact returns the exact entries for name.
+func (tr *labelTrie) exact(name string, one *[1]int) []int {
+	n := int32(0)
+	for rest := name; rest != ""; {
+		var label string
+		label, rest = lastLabel(rest)
+		child, ok := tr.edges[trieEdge{n, label}]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+	return tr.ids(tr.nodes[n].exact, one)
+}
+
+// each calls fn with the leading-wildcard entries of each parent domain of
+// host, the top-level one first, then with those of host and its exact
+// entries. It stops and returns false as soon as fn does.
+func (tr *labelTrie) each(host string, fn func(ids []int) bool) bool {
+	var one [1]int
+	n := int32(0)
+	for rest := host; rest != ""; {
+		var label string
+		label, rest = lastLabel(rest)
+		child, ok := tr.edges[trieEdge{n, label}]
+		if !ok {
+			return true
+		}
+		n = child
+		if !fn(tr.ids(tr.nodes[n].suffix, &one)) {
+			return false
+		}
+	}
+	return fn(tr.ids(tr.nodes[n].exact, &one))
+}
+
+// lastLabel splits name into its last label and what precedes it.
+func lastLabel(name string) (label, rest string) {
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 {
+		return name, ""
+	}
+	return name[dot+1:], name[:dot]
+}
@@ -0,0 +1,48 @@
+package nscope
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// filterXML copies doc to w, dropping every element named element for which
+// keep returns false. keep must consume the element from the decoder.
+func filterXML(doc []byte, w io.Writer, element string, keep func(*xml.Decoder, *xml.StartElement) (bool, error)) error {
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	copied := int64(0)
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != element {
+			continue
+		}
+		ok, err = keep(dec, &se)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		for start > copied && (doc[start-1] == ' ' || doc[start-1] == '\t') {
+			start--
+		}
+		end := dec.InputOffset()
+		if end < int64(len(doc)) && doc[end] == '\n' {
+			end++
+		}
+		if _, err := w.Write(doc[copied:start]); err != nil {
+			return err
+		}
+		copied = end
+	}
+	_, err := w.Write(doc[copied:])
+	return err
+}
@@ -0,0 +1,153 @@
+package nscope
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlEntry struct {
+	Pattern  string   `yaml:"pattern"`
+	Exclude  bool     `yaml:"exclude"`
+	Tags     []string `yaml:"tags"`
+	Note     string   `yaml:"note"`
+	Severity string   `yaml:"severity"`
+	Expires  string   `yaml:"expires"`
+}
+
+var yamlEntryFields = map[string]bool{"pattern": true, "exclude": true, "tags": true, "note": true, "severity": true, "expires": true}
+
+// isYAMLScope reports whether a scope file is in the YAML format: it has a
+// .yaml or .yml extension, or starts with a document marker or a scope key.
+func isYAMLScope(br *bufio.Reader, path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	b, _ := br.Peek(512)
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return bytes.HasPrefix(b, []byte("---")) || bytes.HasPrefix(b, []byte("scope:"))
+}
+
+// readYAMLScope reads a scope written as YAML: a list of entries, either at
+// the top level or under a scope key. An entry is a rule as it would appear
+// on a line of a text scope, or a mapping with the rule under pattern and
+// optional exclude, tags, note, severity and expires fields.
+func readYAMLScope(r io.Reader, path string, lo LoadOptions) (*Scope, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return newScope(nil), nil
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+			k, v := doc.Content[0].Content[i], doc.Content[0].Content[i+1]
+			if k.Value != "scope" {
+				return nil, fmt.Errorf("%s:%d: unknown key %q", path, k.Line, k.Value)
+			}
+			list = v
+		}
+	}
+	if list == nil || list.Tag == "!!null" {
+		return newScope(nil), nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: want a list of scope entries", path, list.Line)
+	}
+	var out []scopeEntry
+	var pk *scopePacker
+	if lo.Compact {
+		pk = newScopePacker(lo.KeepRaw)
+	}
+	for _, n := range list.Content {
+		var ye yamlEntry
+		switch n.Kind {
+		case yaml.ScalarNode:
+			ye.Pattern = n.Value
+		case yaml.MappingNode:
+			for i := 0; i < len(n.Content); i += 2 {
+				if k := n.Content[i]; !yamlEntryFields[k.Value] {
+					return nil, fmt.Errorf("%s:%d: unknown field %q", path, k.Line, k.Value)
+				}
+			}
+			if err := n.Decode(&ye); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n.Line, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: want a pattern or a mapping", path, n.Line)
+		}
+		pattern := strings.TrimSpace(ye.Pattern)
+		if pattern == "" {
+			return nil, fmt.Errorf("%s:%d: entry has no pattern", path, n.Line)
+		}
+		ent, err := lo.parseEntry(pattern, path, n.Line)
+		if err != nil {
+			return nil, err
+		}
+		ent.exclude = ent.exclude || ye.Exclude
+		if len(ye.Tags) > 0 || ye.Note != "" || ye.Severity != "" || ye.Expires != "" {
+			meta := ent.setInfo()
+			meta.tags, meta.note, meta.severity = ye.Tags, ye.Note, ye.Severity
+			if ye.Expires != "" {
+				if meta.expires, err = parseExpiry(ye.Expires); err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", path, n.Line, err)
+				}
+			}
+		}
+		if pk != nil {
+			pk.pack(&ent)
+		}
+		out = append(out, ent)
+	}
+	if pk != nil {
+		// Drop the spare capacity left by append.
+		out = slices.Clone(out)
+	}
+	m := newScope(out)
+	if err := m.expandASNs(lo); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseExpiry parses an expires value, a date or an RFC 3339 time. A date
+// means midnight UTC at its start.
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expires %q: want a date (2006-01-02) or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+func (e *scopeEntry) expiredAt(t time.Time) bool {
+	expires := e.info().expires
+	return !expires.IsZero() && !t.Before(expires)
+}
+
+// ExpiredEntries returns the rules left out of matching because their
+// expiry time had passed when the scope was built.
+func (m *Scope) ExpiredEntries() []Rule {
+	var rules []Rule
+	for i := range m.entries {
+		if m.entries[i].expiredAt(m.asOf) {
+			rules = append(rules, m.ruleAt(i))
+		}
+	}
+	return rules
+}
@@ -0,0 +1,349 @@
+package nscope
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+type zapConfig struct {
+	XMLName xml.Name   `xml:"configuration"`
+	Context zapContext `xml:"context"`
+}
+
+type zapContext struct {
+	Name    string   `xml:"name"`
+	Desc    string   `xml:"desc"`
+	InScope bool     `xml:"inscope"`
+	Include []string `xml:"incregexes"`
+	Exclude []string `xml:"excregexes"`
+}
+
+var (
+	zapAnyScheme  = []string{`https?://`, `(http|https)://`, `(?:http|https)://`, `.*://`, `[a-z]+://`}
+	zapSubdomains = []string{`([^/:]*\.)?`, `(?:[^/:]*\.)?`, `(.*\.)?`, `(?:.*\.)?`, `([^/]*\.)?`, `(?:[^/]*\.)?`, `.*\.`, `[^/]*\.`}
+	zapAnyPort    = []string{`(:[0-9]+)?`, `(:\d+)?`, `(?::[0-9]+)?`, `(?::\d+)?`}
+	zapPortRe     = regexp.MustCompile(`^(\()?:(?:\((?:\?:)?([0-9|]+)\)|([0-9]+))(\)\?)?`)
+	zapOctetRe    = regexp.MustCompile(`^(?:\([0-9]+(?:\|[0-9]+)*\)|\[0-9\]\{1,3\})`)
+	zapSchemeRe   = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+	zapQuoteRe    = regexp.MustCompile(`\\Q(.*?)(?:\\E|$)`)
+)
+
+func ParseZAPContext(b []byte, file string) (*Scope, error) {
+	var cfg zapConfig
+	if err := xml.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	var out []scopeEntry
+	n := 0
+	for _, list := range []struct {
+		exprs   []string
+		exclude bool
+	}{{cfg.Context.Include, false}, {cfg.Context.Exclude, true}} {
+		for _, expr := range list.exprs {
+			n++
+			e, err := zapEntry(strings.TrimSpace(expr))
+			if err != nil {
+				return nil, fmt.Errorf("%s: regex %d: %w", file, n, err)
+			}
+			e.exclude = list.exclude
+			e.raw = e.canonical()
+			e.altered = false
+			e.file = file
+			e.line = n
+			out = append(out, e)
+		}
+	}
+	return newScope(out), nil
+}
+
+func zapEntry(expr string) (scopeEntry, error) {
+	unsupported := fmt.Errorf("unsupported URL pattern %q", expr)
+	s := zapQuoteRe.ReplaceAllStringFunc(expr, func(q string) string {
+		return regexp.QuoteMeta(zapQuoteRe.FindStringSubmatch(q)[1])
+	})
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "^"), "$")
+
+	var scheme string
+	if rest, ok := cutAnyPrefix(s, zapAnyScheme); ok {
+		s = rest
+	} else if sc, rest, ok := strings.Cut(s, "://"); ok && zapSchemeRe.MatchString(sc) {
+		scheme, s = sc, rest
+	} else {
+		return scopeEntry{}, unsupported
+	}
+
+	var host, hostRe strings.Builder
+	literal := true
+	if rest, ok := cutAnyPrefix(s, zapSubdomains); ok {
+		host.WriteString("*.")
+		hostRe.WriteString(`(.*\.)?`)
+		s = rest
+	}
+hostLoop:
+	for s != "" {
+		if m := zapOctetRe.FindString(s); m != "" {
+			hostRe.WriteString(m)
+			literal = false
+			s = s[len(m):]
+			continue
+		}
+		if g := groupPrefix(s); g != "" {
+			hostRe.WriteString(g)
+			literal = false
+			s = s[len(g):]
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s, `[^/:]*`):
+			hostRe.WriteString(`[^/:]*`)
+			literal = false
+			s = s[len(`[^/:]*`):]
+		case strings.HasPrefix(s, `[^./:]+(?:\.[^./:]+)*`):
+			host.WriteString("**")
+			hostRe.WriteString(`[^.]+(\.[^.]+)*`)
+			s = s[len(`[^./:]+(?:\.[^./:]+)*`):]
+		case strings.HasPrefix(s, `[^./:]*`):
+			host.WriteByte('*')
+			hostRe.WriteString(`[^.]*`)
+			s = s[len(`[^./:]*`):]
+		case strings.HasPrefix(s, `[^./:]+`):
+			host.WriteByte('*')
+			hostRe.WriteString(`[^.]+`)
+			s = s[len(`[^./:]+`):]
+		case strings.HasPrefix(s, `\.`), s[0] == '.' && !strings.HasPrefix(s, ".*"):
+			host.WriteByte('.')
+			hostRe.WriteString(`\.`)
+			s = strings.TrimPrefix(s[1:], ".")
+		case isHostByte(s[0]):
+			host.WriteByte(s[0])
+			hostRe.WriteByte(s[0])
+			s = s[1:]
+		default:
+			break hostLoop
+		}
+	}
+	if host.Len() == 0 && hostRe.Len() == 0 {
+		return scopeEntry{}, unsupported
+	}
+
+	var port string
+	if rest, ok := cutAnyPrefix(s, zapAnyPort); ok {
+		s = rest
+	} else if s = strings.Replace(s, "(?::", "(:", 1); strings.HasPrefix(s, ":") || strings.HasPrefix(s, "(:") {
+		m := zapPortRe.FindStringSubmatch(s)
+		if m == nil || (m[1] == "") != (m[4] == "") || m[2] != "" && !burpPortsRe.MatchString(m[2]) {
+			return scopeEntry{}, unsupported
+		}
+		port = strings.ReplaceAll(m[2]+m[3], "|", ",")
+		s = s[len(m[0]):]
+	}
+
+	var pb strings.Builder
+pathLoop:
+	for s != "" {
+		switch {
+		case len(s) > 1 && s[0] == '\\' && strings.IndexByte(`./-?=&+`, s[1]) != -1:
+			pb.WriteByte(s[1])
+			s = s[2:]
+		case s[0] == '.' && !strings.HasPrefix(s, ".*"):
+			pb.WriteByte('.')
+			s = s[1:]
+		case s[0] == '/' && !strings.HasPrefix(s, "/.*"), isPathByte(s[0]):
+			pb.WriteByte(s[0])
+			s = s[1:]
+		default:
+			break pathLoop
+		}
+	}
+	path := pb.String()
+	if path != "" && path[0] != '/' {
+		return scopeEntry{}, unsupported
+	}
+	switch s {
+	case "", `([?#].*)?`, `(?:[?#].*)?`, `(\?.*)?`:
+		if path == "/" {
+			path = ""
+		}
+	case ".*":
+		if path != "" {
+			path += "*"
+		}
+	case `(/.*)?`, `(?:/.*)?`, `/.*`:
+		if path != "" {
+			path = strings.TrimSuffix(path, "/") + "/*"
+		}
+	case `([/?#].*)?`, `(?:[/?#].*)?`:
+		if path != "" {
+			return scopeEntry{}, unsupported
+		}
+	default:
+		return scopeEntry{}, unsupported
+	}
+
+	rule := host.String()
+	if !literal {
+		rule = "re:^" + hostRe.String() + "$"
+	}
+	e, err := parseScopeLine(rule)
+	if err != nil {
+		return scopeEntry{}, err
+	}
+	e.scheme, e.port, e.path = scheme, port, path
+	if port != "" {
+		if e.ports, err = parsePortSpec(port); err != nil {
+			return scopeEntry{}, err
+		}
+	}
+	return e, nil
+}
+
+func groupPrefix(s string) string {
+	if !strings.HasPrefix(s, "(?:") {
+		return ""
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s[:i+1]
+			}
+		}
+	}
+	return ""
+}
+
+func cutAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return rest, true
+		}
+	}
+	return s, false
+}
+
+func isHostByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_'
+}
+
+func isPathByte(c byte) bool {
+	return isHostByte(c) || strings.IndexByte("~%!,;=@:", c) != -1
+}
+
+func (m *Scope) zapContext(name string) (zapConfig, error) {
+	cfg := zapConfig{Context: zapContext{Name: name, InScope: true}}
+	for _, e := range m.entries {
+		patterns, err := e.zapPatterns()
+		if err != nil {
+			return zapConfig{}, fmt.Errorf("%s: %s: %w", e.location(), e.label(), err)
+		}
+		if e.exclude {
+			cfg.Context.Exclude = append(cfg.Context.Exclude, patterns...)
+		} else {
+			cfg.Context.Include = append(cfg.Context.Include, patterns...)
+		}
+	}
+	return cfg, nil
+}
+
+func (m *Scope) MarshalZAP(name string) ([]byte, error) {
+	cfg, err := m.zapContext(name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := xml.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func (e scopeEntry) zapPatterns() ([]string, error) {
+	var hosts []string
+	switch e.kind {
+	case scopeExact:
+		h := regexp.QuoteMeta(e.base)
+		if strings.Contains(e.base, ":") {
+			h = `\[` + h + `\]`
+		}
+		hosts = []string{h}
+	case scopeLeadingWildcard:
+		hosts = []string{`([^/:]*\.)?` + regexp.QuoteMeta(e.base)}
+	case scopePatternWildcard:
+		labels := make([]string, len(e.patternLabels))
+		for i, l := range e.patternLabels {
+			labels[i] = labelRegexp(l, `[^./:]`)
+		}
+		hosts = []string{strings.Join(labels, `\.`)}
+	case scopeTLDWildcard:
+		return nil, fmt.Errorf("public suffix wildcards cannot be expressed as a regex")
+	case scopeAny:
+		hosts = []string{`[^/?#]+`}
+	case scopeRegex:
+		body, anchoredStart := strings.CutPrefix(e.base, "^")
+		body, anchoredEnd := strings.CutSuffix(body, "$")
+		h := "(?:" + body + ")"
+		if !anchoredStart {
+			h = `[^/:]*` + h
+		}
+		if !anchoredEnd {
+			h += `[^/:]*`
+		}
+		hosts = []string{h}
+	case scopeCIDR, scopeRange, scopeASN:
+		prefixes := e.info().prefixes
+		if e.kind == scopeCIDR {
+			prefixes = []netip.Prefix{e.network}
+		}
+		for _, p := range prefixes {
+			h, err := prefixPattern(p)
+			if err != nil {
+				return nil, err
+			}
+			if p.Addr().Is6() {
+				h = `\[` + h + `\]`
+			}
+			hosts = append(hosts, h)
+		}
+	}
+
+	scheme := `https?://`
+	if e.scheme != "" {
+		scheme = regexp.QuoteMeta(e.scheme) + "://"
+	}
+	port := `(:[0-9]+)?`
+	if e.port != "" {
+		ports, err := e.portList()
+		if err != nil {
+			return nil, err
+		}
+		port = ":(" + strings.Join(ports, "|") + ")"
+		for _, p := range ports {
+			if p == defaultPorts[e.scheme] || e.scheme == "" && (p == "80" || p == "443") {
+				port = "(" + port + ")?"
+				break
+			}
+		}
+	}
+	path := `([/?#].*)?`
+	if e.path != "" {
+		if prefix, ok := strings.CutSuffix(e.path, "*"); ok {
+			path = regexp.QuoteMeta(prefix) + ".*"
+		} else {
+			path = regexp.QuoteMeta(e.path) + `([?#].*)?`
+		}
+	}
+
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		out = append(out, scheme+h+port+path)
+	}
+	return out, nil
+}
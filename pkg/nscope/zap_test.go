@@ -0,0 +1,39 @@
+package nscope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZAPRoundTrip(t *testing.T) {
+	const scope = `example.com
+*.test.com
+staging.*.example.com
+dev-*.example.com
+api*.prod.example.com:8443
+**.internal.example.com
+api.**.prod.example.com
+*x*.example.org/app/*
+!a-*-b.example.com
+`
+	m, err := ParseScope(strings.NewReader(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.MarshalZAP("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := ParseZAPContext(b, "test.context")
+	if err != nil {
+		t.Fatalf("importing the exported context: %v\n%s", err, b)
+	}
+	if len(back.entries) != len(m.entries) {
+		t.Fatalf("got %d entries back, want %d", len(back.entries), len(m.entries))
+	}
+	for i, e := range m.entries {
+		if got, want := back.entries[i].canonical(), e.canonical(); got != want {
+			t.Errorf("entry %d came back as %q, want %q", i, got, want)
+		}
+	}
+}
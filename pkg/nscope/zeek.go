@@ -0,0 +1,146 @@
+package nscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var zeekHostFields = []string{"query", "host", "server_name"}
+
+// ProcessZeek filters Zeek dns.log, http.log and ssl.log files, in either the
+// TSV or the JSON format, keeping the records whose query, host or
+// server_name, or failing that the responder address, is in scope. Header
+// lines of TSV logs are passed through.
+func ProcessZeek(r io.Reader, w io.Writer, scope *Scope, opts Options) (Stats, error) {
+	var st Stats
+	sep := "\t"
+	var cols map[string]int
+	scanner := NewLineScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if v, ok := strings.CutPrefix(line, "#separator "); ok {
+				sep = unescapeZeek(v)
+			} else if v, ok := strings.CutPrefix(line, "#fields"+sep); ok {
+				cols = make(map[string]int)
+				for i, f := range strings.Split(v, sep) {
+					cols[f] = i
+				}
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return st, err
+			}
+			continue
+		}
+		st.Lines++
+		res := Result{LineNo: st.Lines, Line: line}
+		if strings.TrimSpace(line) == "" {
+			skipLine(&res, &st, RejectEmpty)
+			continue
+		}
+		var field func(string) string
+		if strings.HasPrefix(line, "{") {
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				rejectLine(&res, &st, RejectRecord)
+				continue
+			}
+			field = func(name string) string {
+				switch v := rec[name].(type) {
+				case string:
+					return v
+				case float64:
+					return strconv.FormatFloat(v, 'f', -1, 64)
+				case []any:
+					var vals []string
+					for _, e := range v {
+						if s, ok := e.(string); ok {
+							vals = append(vals, s)
+						}
+					}
+					return strings.Join(vals, ",")
+				}
+				return ""
+			}
+		} else {
+			values := strings.Split(line, sep)
+			field = func(name string) string {
+				i, ok := cols[name]
+				if !ok || i >= len(values) || values[i] == "-" || values[i] == "(empty)" {
+					return ""
+				}
+				return values[i]
+			}
+		}
+		if !prepareZeekRecord(&res, field, opts, &st) {
+			continue
+		}
+		scope.Classify(&res, opts)
+		st.Count(res.Verdict)
+		if (res.Verdict == Included) != opts.Reverse {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return st, err
+			}
+		}
+	}
+	return st, scanner.Err()
+}
+
+func prepareZeekRecord(res *Result, field func(string) string, opts Options, st *Stats) bool {
+	respH := field("id.resp_h")
+	host, from := respH, ""
+	for _, f := range zeekHostFields {
+		if v := field(f); v != "" {
+			host, from = v, f
+			break
+		}
+	}
+	ex, ok := extractHostFromLine(host)
+	switch {
+	case !ok:
+		return rejectLine(res, st, RejectNoHost)
+	case !withinHostLimits(ex.host, opts.MaxHostLength):
+		return rejectLine(res, st, RejectTooLong)
+	case ex.badURL:
+		return rejectLine(res, st, RejectBadURL)
+	}
+	h, err := normalizeHost(ex.host)
+	if err != nil || h == "" {
+		return rejectLine(res, st, RejectBadHost)
+	}
+	st.Parsed++
+	res.Host, res.Port = h, ex.port
+	// A DNS record's responder is the name server, so the answers stand in
+	// for the address of the queried name.
+	ips := []string{respH}
+	if from == "query" {
+		ips = strings.Split(field("answers"), ",")
+	} else if res.Port == "" {
+		res.Port = field("id.resp_p")
+	}
+	for _, a := range ips {
+		if ip := net.ParseIP(a); ip != nil && ip.String() != h {
+			res.fallbackIPs = append(res.fallbackIPs, ip.String())
+		}
+	}
+	return true
+}
+
+func unescapeZeek(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package nscope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZeekInput(t *testing.T) {
+	const scope = "*.example.com\n10.0.0.0/8\n"
+
+	got, st := processFixture(t, "dns.log", scope, Options{Input: "zeek"}, ProcessZeek)
+	var records []string
+	for _, l := range got {
+		if !strings.HasPrefix(l, "#") {
+			records = append(records, l)
+		}
+	}
+	checkFixture(t, records, st, []string{
+		"1700000000.000000\tC1\t192.168.1.10\t53000\t192.168.1.1\t53\tudp\tapp.example.com\t203.0.113.7",
+		"1700000001.000000\tC2\t192.168.1.10\t53001\t192.168.1.1\t53\tudp\tcdn.other.net\tedge.other.net,10.1.2.3",
+	}, map[string]int{RejectEmpty: 1, RejectBadHost: 1})
+	if n := len(got) - len(records); n != 8 {
+		t.Errorf("passed %d header lines through, want 8", n)
+	}
+
+	got, st = processFixture(t, "http.log.json", scope, Options{Input: "zeek"}, ProcessZeek)
+	checkFixture(t, got, st, []string{
+		`{"ts":1700000000.0,"uid":"C5","id.orig_h":"192.168.1.10","id.orig_p":50001,"id.resp_h":"203.0.113.7","id.resp_p":443,"host":"admin.example.com","uri":"/"}`,
+		`{"ts":1700000001.0,"uid":"C6","id.orig_h":"192.168.1.10","id.orig_p":50002,"id.resp_h":"10.1.2.3","id.resp_p":8080,"uri":"/status"}`,
+	}, map[string]int{RejectRecord: 1, RejectBadHost: 1})
+	got, _ = processFixture(t, "http.log.json", scope, Options{Input: "zeek", Reverse: true}, ProcessZeek)
+	if len(got) != 1 || !strings.Contains(got[0], `"host":"other.org"`) {
+		t.Errorf("with Reverse printed %q, want only the other.org record", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+// profileExts are the scope file extensions a profile can have, in the order
+// they are looked for.
+var profileExts = []string{".txt", ".yaml", ".yml", ".json"}
+
+// profilesDir returns the directory holding named scope profiles,
+// ~/.config/nscope/scopes on Linux.
+func profilesDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "scopes"), nil
+}
+
+// profilePath returns the scope file of the profile called name.
+func profilePath(name string) (string, error) {
+	if !scopeNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	dir, err := profilesDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range profileExts {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no scope profile %q in %s (see nscope profiles add)", name, dir)
+}
+
+func runProfiles(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nscope profiles list|add|rm")
+	}
+	dir, err := profilesDir()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || !slices.Contains(profileExts, ext) {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\n", strings.TrimSuffix(e.Name(), ext), filepath.Join(dir, e.Name()))
+		}
+		return nil
+	case "add":
+		return addProfile(dir, args[1:])
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: nscope profiles rm NAME")
+		}
+		path, err := profilePath(args[1])
+		if err != nil {
+			return err
+		}
+		return os.Remove(path)
+	}
+	return fmt.Errorf("unknown profiles command %q (want list, add or rm)", args[0])
+}
+
+// addProfile copies a scope file into the profiles directory after checking
+// that it parses.
+func addProfile(dir string, args []string) error {
+	fs := flag.NewFlagSet("profiles add", flag.ExitOnError)
+	force := fs.Bool("f", false, "replace an existing profile of the same name")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: nscope profiles add [-f] NAME FILE")
+	}
+	name, src := fs.Arg(0), fs.Arg(1)
+	if !scopeNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	var b []byte
+	var err error
+	if src == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return err
+	}
+	// Only the syntax is checked here; as: entries are expanded when the
+	// profile is used.
+	lo := nscope.LoadOptions{KeepASNs: true}
+	if _, err := nscope.ReadScope(bytes.NewReader(b), src, lo); err != nil {
+		return fmt.Errorf("reading scope file: %w", err)
+	}
+	ext := strings.ToLower(filepath.Ext(src))
+	if !slices.Contains(profileExts, ext) {
+		ext = ".txt"
+	}
+	if old, err := profilePath(name); err == nil {
+		if !*force {
+			return fmt.Errorf("scope profile %q already exists (use -f to replace it)", name)
+		}
+		if err := os.Remove(old); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+ext), b, 0o644)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+// scopeFingerprint summarizes the size and modification time of every local
+// file behind specs, so a change to any of them, or a file added to a scope
+// directory, is noticed.
+func scopeFingerprint(specs []string) string {
+	paths, _ := expandPaths(specs, true)
+	var b strings.Builder
+	for _, p := range paths {
+		if p == "-" || strings.Contains(p, "://") {
+			continue
+		}
+		if fi, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&b, "%s\x00%d\x00%d\n", p, fi.Size(), fi.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&b, "%s\x00missing\n", p)
+		}
+	}
+	return b.String()
+}
+
+// scopeDirs returns the directories that hold the local files behind specs,
+// and every directory under a directory spec. They are watched rather than
+// the files, so that editors that replace a file on save and files added to
+// a scope directory are noticed.
+func scopeDirs(specs []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(d string) {
+		if !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	paths, _ := expandPaths(specs, true)
+	for _, p := range paths {
+		if p != "-" && !strings.Contains(p, "://") {
+			add(filepath.Dir(p))
+		}
+	}
+	for _, spec := range specs {
+		for _, p := range strings.Split(spec, ",") {
+			if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+				filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+					if err == nil && d.IsDir() {
+						add(path)
+					}
+					return nil
+				})
+			}
+		}
+	}
+	return dirs
+}
+
+// watchScopeFiles returns a watcher on the directories of scopeDirs.
+func watchScopeFiles(specs []string) (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range scopeDirs(specs) {
+		if err := w.Add(d); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// reloadSettle is how long reloadScope waits after a file event for more
+// to arrive, so that a file written in several steps is loaded once.
+const reloadSettle = 100 * time.Millisecond
+
+// reloadScope watches the files behind specs and stores a freshly built
+// scope in live whenever they change. Where the files cannot be watched it
+// checks them every interval instead. A scope that fails to load is reported
+// and the previous one stays in use.
+func reloadScope(ctx context.Context, specs []string, interval time.Duration, build func() (*nscope.Scope, error), live *atomic.Pointer[nscope.Scope], log io.Writer) {
+	last := scopeFingerprint(specs)
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	var tick, settle <-chan time.Time
+	w, err := watchScopeFiles(specs)
+	if err != nil {
+		fmt.Fprintf(log, "warning: cannot watch the scope files, checking them every %s: %v\n", interval, err)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	} else {
+		defer w.Close()
+		events, errs = w.Events, w.Errors
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			} else {
+				settle = time.After(reloadSettle)
+			}
+			continue
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+			} else {
+				fmt.Fprintf(log, "warning: watching the scope files: %v\n", err)
+			}
+			continue
+		case <-settle:
+			settle = nil
+		case <-tick:
+		}
+		cur := scopeFingerprint(specs)
+		if cur == last {
+			continue
+		}
+		last = cur
+		if w != nil {
+			for _, d := range scopeDirs(specs) {
+				w.Add(d)
+			}
+		}
+		m, err := build()
+		if err != nil {
+			fmt.Fprintf(log, "error reloading scope, keeping the previous one: %v\n", err)
+			continue
+		}
+		live.Store(m)
+		fmt.Fprintf(log, "scope reloaded: %d rules\n", m.MemStats().Entries)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+func resolverSpec(resolvers, doh string) (string, error) {
+	var servers []string
+	if f, err := os.Open(resolvers); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				servers = append(servers, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return "", err
+		}
+	} else if resolvers != "" && (doh == "" || resolvers != "system") {
+		servers = append(servers, resolvers)
+	}
+	if doh != "" {
+		servers = append(servers, strings.Split(doh, ",")...)
+	}
+	return strings.Join(servers, ","), nil
+}
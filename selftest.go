@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+// scopeTest is one assertion of an nscope test file: a target and whether it
+// must be in scope.
+type scopeTest struct {
+	line   int
+	target string
+	in     bool
+}
+
+func runTest(args []string, w io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	verbose := fs.Bool("v", false, "also print the tests that pass")
+	fs.Parse(args)
+
+	if *scopeFile == "" {
+		return false, fmt.Errorf("-s scope file is required")
+	}
+	if fs.NArg() == 0 {
+		return false, fmt.Errorf("usage: nscope test -s SCOPE TESTFILE...")
+	}
+	scope, err := loadScopeFiles(*scopeFile, nscope.LoadOptions{})
+	if err != nil {
+		return false, fmt.Errorf("reading scope file: %w", err)
+	}
+
+	total, failed := 0, 0
+	for _, path := range fs.Args() {
+		tests, err := readScopeTests(path)
+		if err != nil {
+			return false, err
+		}
+		targets := make([]string, len(tests))
+		for i, t := range tests {
+			targets[i] = t.target
+		}
+		opts := nscope.Options{MaxHostLength: nscope.DefaultMaxHostLength, Rules: true}
+		_, err = nscope.ScanLines(strings.NewReader(strings.Join(targets, "\n")), scope, opts, func(res *nscope.Result) error {
+			t := tests[res.LineNo-1]
+			total++
+			got := res.Verdict.String()
+			if res.Invalid || res.Skipped {
+				got = "invalid"
+			}
+			pass := (got == "included") == t.in
+			want := "expect-out"
+			if t.in {
+				want = "expect-in"
+			}
+			if !pass {
+				failed++
+				_, err := fmt.Fprintf(w, "FAIL %s:%d: %s: %s, got %s (%s)\n", path, t.line, t.target, want, got, res.Rule.Describe())
+				return err
+			}
+			if *verbose {
+				_, err := fmt.Fprintf(w, "ok   %s:%d: %s: %s\n", path, t.line, t.target, want)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d tests failed\n", failed, total)
+		return false, nil
+	}
+	fmt.Fprintf(w, "%d tests passed\n", total)
+	return true, nil
+}
+
+// readScopeTests reads a test file: one target per line followed by
+// expect-in or expect-out. Blank lines and comments starting with a # word
+// are ignored.
+func readScopeTests(path string) ([]scopeTest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tests []scopeTest
+	sc := bufio.NewScanner(f)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		fields := strings.Fields(sc.Text())
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || (fields[1] != "expect-in" && fields[1] != "expect-out") {
+			return nil, fmt.Errorf("%s:%d: want TARGET expect-in or TARGET expect-out", path, lineNo)
+		}
+		tests = append(tests, scopeTest{line: lineNo, target: fields[0], in: fields[1] == "expect-in"})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+// scopeServer keeps a parsed scope resident and answers queries against it.
+// SIGHUP reloads the scope files.
+type scopeServer struct {
+	spec    string
+	lo      nscope.LoadOptions
+	scope   atomic.Pointer[nscope.Scope]
+	opts    nscope.Options
+	metrics *serverMetrics
+}
+
+func (s *scopeServer) reload() error {
+	m, err := loadScopeFiles(s.spec, s.lo)
+	if err != nil {
+		return err
+	}
+	s.scope.Store(m)
+	return nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	scopeFile := fs.String("s", "", "file containing scope domains (required)")
+	unixPath := fs.String("unix", "", "answer newline-delimited queries on this Unix socket")
+	httpAddr := fs.String("http", "", "serve the HTTP API on this address, e.g. :8080")
+	grpcAddr := fs.String("grpc", "", "serve the gRPC API of api/nscope.proto on this address, e.g. :9090")
+	fs.Parse(args)
+
+	if *scopeFile == "" || (*unixPath == "" && *httpAddr == "" && *grpcAddr == "") {
+		return fmt.Errorf("usage: nscope serve -s SCOPE [-unix PATH] [-http ADDR] [-grpc ADDR]")
+	}
+	s := &scopeServer{spec: *scopeFile, metrics: newServerMetrics()}
+	if err := s.reload(); err != nil {
+		return fmt.Errorf("reading scope file: %w", err)
+	}
+	s.opts = nscope.Options{MaxHostLength: nscope.DefaultMaxHostLength, Rules: true, LiveScope: &s.scope}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go s.reloadOnHangup(ctx)
+
+	errs := make(chan error, 3)
+	if *unixPath != "" {
+		if err := os.Remove(*unixPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		ln, err := net.Listen("unix", *unixPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(*unixPath)
+		go func() {
+			<-ctx.Done()
+			ln.Close()
+		}()
+		fmt.Fprintf(os.Stderr, "serving %s on %s\n", *scopeFile, *unixPath)
+		go func() { errs <- s.acceptLines(ctx, ln) }()
+	}
+	if *httpAddr != "" {
+		srv := &http.Server{Addr: *httpAddr, Handler: s.handler()}
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		fmt.Fprintf(os.Stderr, "serving %s on http://%s\n", *scopeFile, *httpAddr)
+		go func() {
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				errs <- err
+				return
+			}
+			errs <- nil
+		}()
+	}
+	if *grpcAddr != "" {
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		srv := s.grpcServer()
+		go func() {
+			<-ctx.Done()
+			srv.Stop()
+		}()
+		fmt.Fprintf(os.Stderr, "serving %s over gRPC on %s\n", *scopeFile, ln.Addr())
+		go func() { errs <- srv.Serve(ln) }()
+	}
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (s *scopeServer) acceptLines(ctx context.Context, ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveLines(conn)
+	}
+}
+
+func (s *scopeServer) reloadOnHangup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		if err := s.reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "error reloading scope, keeping the previous one: %v\n", err)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "scope reloaded")
+	}
+}
+
+// serveLines answers every line read from conn with one line in the format
+// of nscope check: the query, its verdict (or "invalid") and the rule.
+func (s *scopeServer) serveLines(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	w := bufio.NewWriter(conn)
+	scanner := nscope.NewLineScanner(conn)
+	for scanner.Scan() {
+		res, ok := s.match(scanner.Text())
+		verdict, rule := res.Verdict.String(), res.Rule.Describe()
+		if !ok {
+			verdict, rule = "invalid", "no rule"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", res.Line, verdict, rule); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// match classifies a single query against the current scope and records it
+// in the metrics.
+func (s *scopeServer) match(line string) (nscope.Result, bool) {
+	start := time.Now()
+	var st nscope.Stats
+	res := nscope.Result{LineNo: 1, Line: line}
+	ok := nscope.PrepareLine(&res, s.opts, &st)
+	if ok {
+		s.scope.Load().Classify(&res, s.opts)
+	}
+	s.metrics.observe(&res, ok, time.Since(start))
+	return res, ok
+}
+
+// matchTarget answers one query of the HTTP or gRPC API.
+func (s *scopeServer) matchTarget(target string) matchResult {
+	res, ok := s.match(target)
+	if !ok {
+		return matchResult{Input: target, Verdict: "invalid", RuleIndex: -1}
+	}
+	return matchResult{
+		Input:     target,
+		Host:      res.Host,
+		Port:      res.Port,
+		Verdict:   res.Verdict.String(),
+		Matched:   res.Verdict == nscope.Included,
+		Rule:      res.Rule.Text,
+		RuleIndex: res.Rule.Index,
+	}
+}
+
+type matchRequest struct {
+	Target  string   `json:"target"`
+	Targets []string `json:"targets"`
+}
+
+type matchResult struct {
+	Input     string `json:"input"`
+	Host      string `json:"host,omitempty"`
+	Port      string `json:"port,omitempty"`
+	Verdict   string `json:"verdict"`
+	Matched   bool   `json:"matched"`
+	Rule      string `json:"rule,omitempty"`
+	RuleIndex int    `json:"rule_index"`
+}
+
+// handler serves POST /match, GET /scope, POST /scope/reload and GET /metrics.
+func (s *scopeServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /match", s.handleMatch)
+	mux.HandleFunc("GET /scope", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.scope.Load())
+	})
+	mux.HandleFunc("POST /scope/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.reload(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"rules": s.scope.Load().MemStats().Entries})
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.metrics.write(w)
+	})
+	return mux
+}
+
+func (s *scopeServer) handleMatch(w http.ResponseWriter, r *http.Request) {
+	var req matchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Targets == nil {
+		if req.Target == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": `request has neither "target" nor "targets"`})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.matchTarget(req.Target))
+		return
+	}
+	results := make([]matchResult, len(req.Targets))
+	for i, t := range req.Targets {
+		results[i] = s.matchTarget(t)
+	}
+	writeJSON(w, http.StatusOK, map[string][]matchResult{"results": results})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
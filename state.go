@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+)
+
+// stateFilter records a 64-bit FNV-1a hash of every output line in a state
+// file, one hexadecimal hash per line, so later runs know what was already
+// emitted. With newOnly set, lines whose hash is already recorded, by an
+// earlier run or earlier in this one, are dropped.
+type stateFilter struct {
+	w       io.Writer
+	newOnly bool
+	sep     byte
+	known   map[uint64]struct{}
+	file    *os.File
+	bw      *bufio.Writer
+	split   lineSplitter
+}
+
+func openStateFilter(w io.Writer, path string, newOnly bool, sep byte) (*stateFilter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[uint64]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// A line cut short by an interrupted run is not a hash; skip it.
+		if h, err := strconv.ParseUint(scanner.Text(), 16, 64); err == nil && len(scanner.Text()) == 16 {
+			known[h] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	return &stateFilter{w: w, newOnly: newOnly, sep: sep, known: known, file: file, bw: bufio.NewWriter(file), split: lineSplitter{sep: sep}}, nil
+}
+
+func (f *stateFilter) Write(p []byte) (int, error) {
+	if err := f.split.write(p, f.line); err != nil {
+		return 0, err
+	}
+	// Flush the hashes with every write so an interrupted run keeps them.
+	if err := f.bw.Flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (f *stateFilter) line(line []byte) error {
+	h := fnv.New64a()
+	h.Write(line)
+	sum := h.Sum64()
+	if _, ok := f.known[sum]; ok {
+		if f.newOnly {
+			return nil
+		}
+	} else {
+		f.known[sum] = struct{}{}
+		fmt.Fprintf(f.bw, "%016x\n", sum)
+	}
+	if _, err := f.w.Write(line); err != nil {
+		return err
+	}
+	_, err := f.w.Write([]byte{f.sep})
+	return err
+}
+
+func (f *stateFilter) Close() error {
+	err := f.split.flush(f.line)
+	if ferr := f.bw.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+func printedLines(st nscope.Stats, reverse bool) int {
+	if reverse {
+		return st.Excluded + st.Unmatched
+	}
+	return st.Included
+}
+
+func printStats(w io.Writer, st nscope.Stats, elapsed time.Duration, dns *nscope.Resolver) {
+	rate := 0.0
+	if s := elapsed.Seconds(); s > 0 {
+		rate = float64(st.Lines) / s
+	}
+	fmt.Fprintf(w, "lines: %d, skipped: %d, parsed: %d, unparsable: %d\n", st.Lines, st.Skipped, st.Parsed, st.Invalid)
+	if st.Fallback > 0 {
+		fmt.Fprintf(w, "parsed by lenient URL fallback: %d\n", st.Fallback)
+	}
+	if st.IDNARejected > 0 {
+		fmt.Fprintf(w, "rejected by IDNA validation: %d\n", st.IDNARejected)
+	}
+	if len(st.Rejects) > 0 {
+		reasons := slices.Sorted(maps.Keys(st.Rejects))
+		counts := make([]string, len(reasons))
+		for i, r := range reasons {
+			counts[i] = fmt.Sprintf("%s %d", r, st.Rejects[r])
+		}
+		fmt.Fprintf(w, "skipped or unparsable by reason: %s\n", strings.Join(counts, ", "))
+	}
+	fmt.Fprintf(w, "matched: %d, excluded: %d, unmatched: %d\n", st.Included, st.Excluded, st.Unmatched)
+	if dns != nil {
+		ds := dns.Stats()
+		fmt.Fprintf(w, "dns queries: %d, cache hits: %d, errors: %d, nxdomain: %d\n", ds.Queries, ds.CacheHits, ds.Errors, ds.NXDomain)
+	}
+	fmt.Fprintf(w, "elapsed: %s (%.0f lines/s)\n", elapsed.Round(time.Millisecond), rate)
+}
+
+// scopeStats returns the verdict counts of the i-th named scope, which are
+// missing when no line was classified.
+func scopeStats(st nscope.Stats, i int) nscope.Stats {
+	if i < len(st.ByScope) {
+		return st.ByScope[i]
+	}
+	return nscope.Stats{}
+}
+
+// printScopeStats prints the verdicts of each named scope after the totals
+// of printStats, where a line counts as matched when any scope includes it.
+func printScopeStats(w io.Writer, scopes []*namedScope, st nscope.Stats) {
+	for i, sc := range scopes {
+		ss := scopeStats(st, i)
+		fmt.Fprintf(w, "scope %s: matched: %d, excluded: %d, unmatched: %d\n", sc.name, ss.Included, ss.Excluded, ss.Unmatched)
+	}
+}
+
+func printUnusedRules(w io.Writer, scope *nscope.Scope) {
+	for _, r := range scope.UnusedRules() {
+		fmt.Fprintf(w, "unused: %s\n", r.Describe())
+	}
+}
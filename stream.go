@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const maxReconnectDelay = time.Minute
+
+// relayFunc writes the messages received on one connection to w and returns
+// how many were relayed once the connection fails.
+type relayFunc func(ctx context.Context, w io.Writer) (int, error)
+
+// reconnectingStream turns a message feed into a stream of lines. A dropped
+// connection is redialled with exponential backoff; the stream reports
+// io.EOF once ctx is done.
+func reconnectingStream(ctx context.Context, name string, relay relayFunc, warn io.Writer) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		delay := time.Second
+		for ctx.Err() == nil {
+			n, err := relay(ctx, pw)
+			if ctx.Err() != nil || errors.Is(err, io.ErrClosedPipe) {
+				break
+			}
+			if n > 0 {
+				delay = time.Second
+			}
+			fmt.Fprintf(warn, "%s: %v; reconnecting in %s\n", name, err, delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			delay = min(2*delay, maxReconnectDelay)
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+// writeMessage writes msg to w as a single line, compacting JSON messages
+// that span several lines. Other multi-line messages are dropped.
+func writeMessage(w io.Writer, msg []byte) error {
+	msg = bytes.TrimSpace(msg)
+	if bytes.ContainsAny(msg, "\r\n") {
+		var buf bytes.Buffer
+		if json.Compact(&buf, msg) != nil {
+			return nil
+		}
+		msg = buf.Bytes()
+	}
+	_, err := w.Write(append(msg, '\n'))
+	return err
+}
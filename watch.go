@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nlxz/nscope/pkg/nscope"
+)
+
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func watchDirectory(ctx context.Context, dir, statePath string, interval time.Duration, scope *nscope.Scope, opts nscope.Options, w io.Writer, log io.Writer) error {
+	done, err := loadWatchState(statePath)
+	if err != nil {
+		return err
+	}
+	state, err := os.OpenFile(statePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	stateName := filepath.Base(statePath)
+	pending := make(map[string]pendingFile)
+	first := true
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, de := range entries {
+			name := de.Name()
+			if !de.Type().IsRegular() || done[name] || name == stateName || isPartialFile(name) {
+				continue
+			}
+			info, err := de.Info()
+			if err != nil {
+				continue
+			}
+			cur := pendingFile{size: info.Size(), modTime: info.ModTime()}
+			if prev, ok := pending[name]; !first && (!ok || prev != cur) {
+				pending[name] = cur
+				continue
+			}
+			delete(pending, name)
+			st, err := processFile(filepath.Join(dir, name), w, scope, opts)
+			if err != nil {
+				fmt.Fprintf(log, "error processing %s: %v\n", name, err)
+				continue
+			}
+			done[name] = true
+			fmt.Fprintln(state, name)
+			fmt.Fprintf(log, "processed %s: %d lines, %d in scope\n", name, st.Lines, st.Included)
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func processFile(path string, w io.Writer, scope *nscope.Scope, opts nscope.Options) (nscope.Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nscope.Stats{}, err
+	}
+	defer f.Close()
+	r, err := nscope.Decompress(f)
+	if err != nil {
+		return nscope.Stats{}, err
+	}
+	defer r.Close()
+	return nscope.ProcessLines(r, w, scope, opts)
+}
+
+func isPartialFile(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".tmp", ".part", ".partial", ".swp":
+		return true
+	}
+	return false
+}
+
+func loadWatchState(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if name := strings.TrimSpace(sc.Text()); name != "" {
+			done[name] = true
+		}
+	}
+	return done, sc.Err()
+}
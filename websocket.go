@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/net/websocket"
+)
+
+// streamWebSocket reads the messages of a WebSocket feed, such as certstream,
+// as lines, reconnecting when the connection drops.
+func streamWebSocket(ctx context.Context, url string, warn io.Writer) (io.ReadCloser, error) {
+	config, err := websocket.NewConfig(url, "http://localhost/")
+	if err != nil {
+		return nil, err
+	}
+	relay := func(ctx context.Context, w io.Writer) (int, error) {
+		ws, err := config.DialContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+		stop := context.AfterFunc(ctx, func() { ws.Close() })
+		defer stop()
+		defer ws.Close()
+		for n := 0; ; n++ {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return n, err
+			}
+			if err := writeMessage(w, msg); err != nil {
+				return n, err
+			}
+		}
+	}
+	return reconnectingStream(ctx, "websocket", relay, warn), nil
+}